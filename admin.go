@@ -2,6 +2,7 @@ package admin
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
 	"net/http"
 	"sort"
@@ -14,11 +15,50 @@ type Route struct {
 	Path        string
 	Description string
 	wildcard    bool
+	guards      []*authGuard
+	roles       []string
+	pattern     routePattern
+
+	// Lists the paths of a route with parameters on the index page.
+	// Only Name and Description of the returned links are used.
+	indexLinks func() []link
 }
 
 type RouteConfig struct {
 	Route
-	children []RouteConfig
+	children   []RouteConfig
+	middleware []func(http.Handler) http.Handler
+	guards     []*authGuard
+	roles      []string
+	skipCSRF   bool
+	limit      *Limit
+
+	// called once after the routes of the admin handler were validated,
+	// to configure features like the audit log.
+	setup func(admin *adminContext)
+}
+
+// Settings of a route config that are inherited by all of its children.
+type inheritedSettings struct {
+	middleware []func(http.Handler) http.Handler
+	guards     []*authGuard
+	roles      []string
+	skipCSRF   bool
+	limit      *Limit
+}
+
+func (settings inheritedSettings) with(config RouteConfig) inheritedSettings {
+	// the middleware of the parents wraps the middleware of this config.
+	settings.middleware = append(settings.middleware[:len(settings.middleware):len(settings.middleware)], config.middleware...)
+	settings.guards = append(settings.guards[:len(settings.guards):len(settings.guards)], config.guards...)
+	settings.roles = append(settings.roles[:len(settings.roles):len(settings.roles)], config.roles...)
+	settings.skipCSRF = settings.skipCSRF || config.skipCSRF
+
+	// the limit of a parent replaces the limits of its children.
+	if settings.limit == nil {
+		settings.limit = config.limit
+	}
+	return settings
 }
 
 func (rc RouteConfig) Describe(description string) RouteConfig {
@@ -26,45 +66,143 @@ func (rc RouteConfig) Describe(description string) RouteConfig {
 	return rc
 }
 
+// Marks the route as a wildcard route. A wildcard route matches its
+// path and all paths below it.
 func (rc RouteConfig) Wildcard(wildcard bool) RouteConfig {
 	rc.wildcard = wildcard
 	return rc
 }
 
+// Adds middleware to the route and to all of its children, including nested
+// ones. The first middleware is the outermost one and sees the request first.
+// Middleware of a parent config always wraps the middleware of its children.
+func (rc RouteConfig) Use(middleware ...func(http.Handler) http.Handler) RouteConfig {
+	// copy to not share the backing array with other configs.
+	rc.middleware = append(append([]func(http.Handler) http.Handler{}, rc.middleware...), middleware...)
+	return rc
+}
+
 func Describe(desc string, rc RouteConfig) RouteConfig {
 	return rc.Describe(desc)
 }
 
+// Combines multiple route configs into one, e.g. to apply middleware to all of them.
+func Group(configs ...RouteConfig) RouteConfig {
+	return RouteConfig{children: configs}
+}
+
+// Applies the middleware to all given route configs and their children.
+func Use(middleware func(http.Handler) http.Handler, configs ...RouteConfig) RouteConfig {
+	return Group(configs...).Use(middleware)
+}
+
 type adminContext struct {
-	appName string
-	prefix  string
-	routes  []Route
+	appName    string
+	prefix     string
+	routes     []Route
+	auditSinks []AuditSink
+
+	// setup hooks of the route configs, run once all routes are valid.
+	setups []func(admin *adminContext)
 }
 
+// Creates a new admin handler serving the given routes. This method panics,
+// if the routes are invalid or conflict with each other. Use BuildAdminHandler
+// to get an error instead.
 func NewAdminHandler(prefix, appName string, routes ...RouteConfig) http.Handler {
+	handler, err := BuildAdminHandler(prefix, appName, routes...)
+	if err != nil {
+		panic(err)
+	}
+
+	return handler
+}
+
+// Creates a new admin handler serving the given routes. An error is returned,
+// if a route has an invalid path, if a path and method is registered twice or
+// if a route would shadow another one.
+//
+// Requests are dispatched to the most specific route that matches, independent
+// of the order in which the routes were registered.
+func BuildAdminHandler(prefix, appName string, routes ...RouteConfig) (http.Handler, error) {
 	admin := &adminContext{appName: appName, prefix: prefix}
-	admin.addRouteConfig(RouteConfig{children: routes})
 
 	// add overview page
-	admin.addRouteConfig(RouteConfig{Route: Route{
-		Handler: admin.indexHandler(),
-		Path:    "/",
-	}})
+	configs := RouteConfig{children: []RouteConfig{
+		{children: routes},
+		{Route: Route{Handler: admin.indexHandler(), Path: "/"}},
+	}}
+
+	if err := admin.addRouteConfig(configs, inheritedSettings{}); err != nil {
+		return nil, err
+	}
+
+	if err := checkRoutes(admin.routes); err != nil {
+		return nil, err
+	}
+
+	sortRoutes(admin.routes)
 
-	return admin.AsHandler()
+	// only start background work for handlers that are actually built.
+	for _, setup := range admin.setups {
+		setup(admin)
+	}
+
+	return admin.AsHandler(), nil
 }
 
-func (admin *adminContext) addRouteConfig(config RouteConfig) {
+func (admin *adminContext) addRouteConfig(config RouteConfig, inherited inheritedSettings) error {
+	settings := inherited.with(config)
+
+	if config.setup != nil {
+		admin.setups = append(admin.setups, config.setup)
+	}
+
 	for _, route := range config.children {
-		admin.addRouteConfig(route)
+		if err := admin.addRouteConfig(route, settings); err != nil {
+			return err
+		}
 	}
 
 	if config.Path != "" {
 		route := config.Route
 		route.Path = pathOf(config.Path)
 		route.Method = strings.ToUpper(config.Method)
+		route.guards = settings.guards
+		route.roles = settings.roles
+
+		if len(route.roles) > 0 && len(route.guards) == 0 {
+			return fmt.Errorf("route %s requires roles but is not protected by an authenticator", describeRoute(route))
+		}
+
+		if route.Handler != nil {
+			if len(route.roles) > 0 {
+				route.Handler = requireRolesHandler(route.roles, route.Handler)
+			}
+
+			if settings.limit != nil && !settings.limit.isZero() {
+				route.Handler = newLimiter(*settings.limit).handler(route.Handler)
+			}
+
+			if needsCSRFProtection(route.Method) && !settings.skipCSRF {
+				route.Handler = csrfHandler(route.Handler)
+			}
+
+			for idx := len(settings.middleware) - 1; idx >= 0; idx-- {
+				route.Handler = settings.middleware[idx](route.Handler)
+			}
+		}
+
+		pattern, err := parsePattern(route.Path, route.wildcard)
+		if err != nil {
+			return err
+		}
+
+		route.pattern = pattern
 		admin.routes = append(admin.routes, route)
 	}
+
+	return nil
 }
 
 // Creates a handler that can handle multiple pages that are given by the pages map.
@@ -81,14 +219,60 @@ func (a *adminContext) indexHandler() http.HandlerFunc {
 
 	// add index handler
 	return func(w http.ResponseWriter, r *http.Request) {
+		identities := newIdentityCache(r)
+
+		// let the user sign in, so we can show the routes that require roles.
+		if _, login := r.URL.Query()["login"]; login && !identities.authenticatedByAny(a.routes) {
+			challengeAll(w, a.routes)
+			return
+		}
+
+		var hidden bool
+
+		csrfToken := CSRFToken(r)
+
 		var links linkSlice
 		for _, route := range a.routes {
-			if route.Path != "/" {
-				links = append(links, link{
-					Name:        route.Path,
-					Path:        strings.TrimLeft(pathOf(a.prefix, route.Path), "/"),
-					Description: route.Description,
-				})
+			if route.Path == "/" {
+				continue
+			}
+
+			// only show routes the current user is allowed to call.
+			if len(route.roles) > 0 && !identities.hasRoles(route, route.roles) {
+				hidden = true
+				continue
+			}
+
+			routeLink := link{
+				Method:      route.Method,
+				Name:        route.Path,
+				Path:        strings.TrimLeft(pathOf(a.prefix, route.Path), "/"),
+				Description: route.Description,
+				Placeholder: route.pattern.hasParams(),
+				Form:        route.Method == "POST",
+				Unlinkable:  route.Method != "" && route.Method != "GET" && route.Method != "POST",
+				Protected:   len(route.guards) > 0,
+				Roles:       strings.Join(route.roles, ", "),
+			}
+
+			// only hand out tokens to callers that may use the route.
+			if _, ok := identities.passesGuards(route); ok && routeLink.Form {
+				routeLink.CSRFToken = csrfToken
+			}
+
+			if route.indexLinks == nil {
+				links = append(links, routeLink)
+				continue
+			}
+
+			// show the concrete paths instead of the placeholder.
+			for _, generated := range route.indexLinks() {
+				generatedLink := routeLink
+				generatedLink.Name = generated.Name
+				generatedLink.Path = strings.TrimLeft(pathOf(a.prefix, generated.Name), "/")
+				generatedLink.Description = generated.Description
+				generatedLink.Placeholder = false
+				links = append(links, generatedLink)
 			}
 		}
 
@@ -96,8 +280,9 @@ func (a *adminContext) indexHandler() http.HandlerFunc {
 		sort.Sort(links)
 
 		templateContext := indexContext{
-			Links:   links,
-			AppName: a.appName,
+			Links:        links,
+			AppName:      a.appName,
+			HiddenRoutes: hidden,
 		}
 
 		// render template
@@ -113,44 +298,79 @@ func (a *adminContext) indexHandler() http.HandlerFunc {
 }
 
 func (admin *adminContext) AsHandler() http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		path := pathOf(req.URL.Path)
+	if len(admin.auditSinks) > 0 {
+		return auditHandler(admin.auditSinks, admin.dispatch)
+	}
 
-		if path != req.URL.Path {
-			http.Redirect(w, req, path, http.StatusTemporaryRedirect)
-			return
-		}
+	return admin.dispatch
+}
 
-		// remove prefix from path and apply to request
-		path = pathOf(strings.TrimPrefix(path, admin.prefix))
-		req.URL.Path = path
+func (admin *adminContext) dispatch(w http.ResponseWriter, req *http.Request) {
+	path := pathOf(req.URL.Path)
 
-		for _, route := range admin.routes {
-			if routePathMatches(route, path) {
-				if isCompatibleMethod(route.Method, req.Method) {
-					// forward request to the handler
-					route.Handler.ServeHTTP(w, req)
+	if path != req.URL.Path {
+		http.Redirect(w, req, path, http.StatusTemporaryRedirect)
+		return
+	}
 
-				} else {
-					http.Error(w, "Illegale method for this path, allowed: "+route.Method, http.StatusMethodNotAllowed)
-				}
+	// remove prefix from path and apply to request
+	path = pathOf(strings.TrimPrefix(path, admin.prefix))
+	req.URL.Path = path
 
-				return
-			}
+	// collect the methods of all routes that match the path, in case
+	// no route accepts the requests method.
+	var methods []string
+
+	for _, route := range admin.routes {
+		params, ok := route.pattern.match(path)
+		if !ok {
+			continue
+		}
+
+		if isCompatibleMethod(route.Method, req.Method) {
+			// forward request to the handler
+			route.Handler.ServeHTTP(w, withPathParams(req, params))
+			return
 		}
 
+		methods = append(methods, route.Method)
+	}
+
+	if len(methods) == 0 {
 		http.NotFound(w, req)
+		return
+	}
+
+	allowed := allowedMethods(methods)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+	if req.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
+
+	http.Error(w, "Illegal method for this path, allowed: "+strings.Join(allowed, ", "), http.StatusMethodNotAllowed)
 }
 
-func routePathMatches(route Route, path string) bool {
-	routePath := pathOf("/", route.Path)
+// Builds the sorted list of methods for an Allow header. HEAD is implicitly
+// supported by GET routes and OPTIONS is always answered by the handler.
+func allowedMethods(methods []string) []string {
+	unique := map[string]bool{"OPTIONS": true}
+	for _, method := range methods {
+		unique[method] = true
 
-	if route.wildcard {
-		return strings.HasPrefix(path, routePath)
-	} else {
-		return routePath == path
+		if method == "GET" {
+			unique["HEAD"] = true
+		}
 	}
+
+	var allowed []string
+	for method := range unique {
+		allowed = append(allowed, method)
+	}
+
+	sort.Strings(allowed)
+	return allowed
 }
 
 func isCompatibleMethod(expected, actual string) bool {
@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func respondWith(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(name + " " + strings.Join(formatParams(req), ",")))
+	}
+}
+
+func formatParams(req *http.Request) []string {
+	var params []string
+	for _, name := range []string{"name", "path"} {
+		if value, ok := PathParams(req)[name]; ok {
+			params = append(params, name+"="+value)
+		}
+	}
+
+	return params
+}
+
+func TestDispatch(t *testing.T) {
+	handler, err := BuildAdminHandler("/admin", "test",
+		WithHandlerFunc("GET", "/caches", respondWith("list")),
+		WithHandlerFunc("GET", "/caches/{name}", respondWith("get")),
+		WithHandlerFunc("HEAD", "/caches/{name}", respondWith("head")),
+		WithHandlerFunc("DELETE", "/caches/{name}", respondWith("delete")),
+		WithHandlerFunc("", "/caches/{name}", respondWith("any")),
+		WithHandlerFunc("GET", "/caches/users", respondWith("users")),
+		WithHandlerFunc("GET", "/files/{path...}", respondWith("files")),
+		WithHandlerFunc("PUT", "/loglevel", respondWith("put")),
+		WithHandlerFunc("POST", "/loglevel", respondWith("post")),
+		WithHandlerFunc("GET", "/static", respondWith("static")).Wildcard(true),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{"GET", "/admin/caches", http.StatusOK, "list ", ""},
+		{"GET", "/admin/caches/users", http.StatusOK, "users ", ""},
+		{"GET", "/admin/caches/orders", http.StatusOK, "get name=orders", ""},
+		{"HEAD", "/admin/caches/orders", http.StatusOK, "head name=orders", ""},
+		{"HEAD", "/admin/caches/users", http.StatusOK, "users ", ""},
+		{"DELETE", "/admin/caches/orders", http.StatusOK, "delete name=orders", ""},
+		{"PATCH", "/admin/caches/orders", http.StatusOK, "any name=orders", ""},
+		{"GET", "/admin/files/a/b", http.StatusOK, "files path=a/b", ""},
+		{"GET", "/admin/files", http.StatusOK, "files path=", ""},
+		{"GET", "/admin/static/css/main.css", http.StatusOK, "static ", ""},
+		{"HEAD", "/admin/static", http.StatusOK, "static ", ""},
+		{"GET", "/admin/unknown", http.StatusNotFound, "404 page not found\n", ""},
+
+		{"GET", "/admin/loglevel", http.StatusMethodNotAllowed, "", "OPTIONS, POST, PUT"},
+		{"DELETE", "/admin/caches", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{"POST", "/admin/files/a", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/admin/caches", http.StatusNoContent, "", "GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/admin/loglevel", http.StatusNoContent, "", "OPTIONS, POST, PUT"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, nil)
+			req.Header.Set(CSRFHeader, "1")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, rec.Code, rec.Body.String())
+			}
+
+			if test.body != "" && rec.Body.String() != test.body {
+				t.Fatalf("expected body %q, got %q", test.body, rec.Body.String())
+			}
+
+			if allow := rec.Header().Get("Allow"); allow != test.allow {
+				t.Fatalf("expected Allow header %q, got %q", test.allow, allow)
+			}
+		})
+	}
+}
+
+func TestBuildAdminHandlerRejectsConflicts(t *testing.T) {
+	_, err := BuildAdminHandler("/admin", "test",
+		WithHandlerFunc("GET", "/a/{x}", respondWith("a")),
+		WithHandlerFunc("GET", "/{y}/b", respondWith("b")))
+
+	if err == nil || !strings.Contains(err.Error(), "shadows") {
+		t.Fatalf("expected a shadowing error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Networks of the loopback interface, to be used with ParseIPAllowlist.
+var LocalhostNetworks = []string{"127.0.0.0/8", "::1/128"}
+
+// A list of networks that are allowed to access admin routes.
+type IPAllowlist struct {
+	allowed        []netip.Prefix
+	trustedProxies []netip.Prefix
+}
+
+// Parses the networks in CIDR notation, single ip addresses are accepted too.
+// Requests coming from one of the trusted proxies are checked against the
+// address in the X-Forwarded-For header instead of their remote address.
+// Without trusted proxies, the X-Forwarded-For header is ignored.
+func ParseIPAllowlist(allowed []string, trustedProxies []string) (IPAllowlist, error) {
+	var list IPAllowlist
+	var err error
+
+	if list.allowed, err = parsePrefixes(allowed); err != nil {
+		return IPAllowlist{}, err
+	}
+
+	if list.trustedProxies, err = parsePrefixes(trustedProxies); err != nil {
+		return IPAllowlist{}, err
+	}
+
+	return list, nil
+}
+
+func parsePrefixes(networks []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, network := range networks {
+		if !strings.Contains(network, "/") {
+			addr, err := netip.ParseAddr(network)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ip address %q: %s", network, err)
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %s", network, err)
+		}
+
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the address of the client that sent the request. If the request was
+// forwarded by trusted proxies, the X-Forwarded-For header is walked from right
+// to left and the first address that is not a trusted proxy is returned.
+func (list IPAllowlist) clientAddr(req *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	addr = addr.Unmap()
+
+	if !containsAddr(list.trustedProxies, addr) {
+		return addr, true
+	}
+
+	var forwarded []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	for idx := len(forwarded) - 1; idx >= 0; idx-- {
+		forwardedAddr, err := netip.ParseAddr(strings.TrimSpace(forwarded[idx]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+
+		addr = forwardedAddr.Unmap()
+		if !containsAddr(list.trustedProxies, addr) {
+			break
+		}
+	}
+
+	return addr, true
+}
+
+// Returns true, if the client that sent the request is in one of the allowed networks.
+func (list IPAllowlist) Allows(req *http.Request) bool {
+	addr, ok := list.clientAddr(req)
+	return ok && containsAddr(list.allowed, addr)
+}
+
+func (list IPAllowlist) middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !list.Allows(req) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// Restricts the given route configs and all of their children to clients
+// from the networks of the allowlist. All other clients get a 403.
+func RestrictTo(list IPAllowlist, configs ...RouteConfig) RouteConfig {
+	return Use(list.middleware, configs...)
+}
@@ -0,0 +1,204 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A single request to the admin handler, as recorded by the audit log.
+type AuditEntry struct {
+	Time         time.Time
+	Identity     string `json:",omitempty"`
+	RemoteAddr   string
+	ForwardedFor string `json:",omitempty"`
+	Method       string
+	Path         string
+	Status       int
+	Duration     time.Duration
+
+	// The request might have changed the state of the process.
+	Mutating bool
+
+	// Additional information added by the handler, see AddAuditDetail.
+	Details map[string]string `json:",omitempty"`
+}
+
+// An AuditSink receives the audit entries of all admin requests.
+type AuditSink interface {
+	Record(entry AuditEntry)
+}
+
+// Records audit entries as json, one entry per line.
+type jsonLinesSink struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+// Creates an audit sink that writes each entry as a single line of json.
+func JSONLinesAuditSink(writer io.Writer) AuditSink {
+	return &jsonLinesSink{encoder: json.NewEncoder(writer)}
+}
+
+func (sink *jsonLinesSink) Record(entry AuditEntry) {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if err := sink.encoder.Encode(entry); err != nil {
+		log.Printf("Could not write audit entry: %s", err)
+	}
+}
+
+// Keeps the most recent audit entries in memory.
+type auditRing struct {
+	lock    sync.Mutex
+	entries *ring[AuditEntry]
+}
+
+func newAuditRing(capacity int) *auditRing {
+	return &auditRing{entries: newRing[AuditEntry](capacity)}
+}
+
+func (audit *auditRing) Record(entry AuditEntry) {
+	audit.lock.Lock()
+	defer audit.lock.Unlock()
+
+	audit.entries.add(entry)
+}
+
+// Returns the recorded entries, newest first.
+func (audit *auditRing) recent() []AuditEntry {
+	audit.lock.Lock()
+	defer audit.lock.Unlock()
+
+	return audit.entries.newestFirst()
+}
+
+// Records every request to the admin handler. The entries are passed to
+// the given sinks and the most recent entries are kept in memory and can
+// be viewed at /audit. Use JSONLinesAuditSink to write them to a file.
+func WithAudit(capacity int, sinks ...AuditSink) RouteConfig {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	ring := newAuditRing(capacity)
+
+	rc := Describe(
+		"The most recent requests to the admin handler, newest first.",
+		WithGenericValue("/audit", ring.recent))
+
+	rc.setup = func(admin *adminContext) {
+		admin.auditSinks = append(admin.auditSinks, ring)
+		admin.auditSinks = append(admin.auditSinks, sinks...)
+	}
+
+	return rc
+}
+
+// Mutable part of an audit entry, that is filled
+// while the request passes the handler chain.
+type auditRecord struct {
+	identity string
+	details  map[string]string
+}
+
+func auditRecordFromRequest(req *http.Request) *auditRecord {
+	record, _ := req.Context().Value(auditRecordKey).(*auditRecord)
+	return record
+}
+
+// Adds a detail, like the new value of a setting, to the audit entry of the
+// current request. Does nothing, if the admin handler does not use WithAudit.
+func AddAuditDetail(req *http.Request, key, value string) {
+	record := auditRecordFromRequest(req)
+	if record == nil {
+		return
+	}
+
+	if record.details == nil {
+		record.details = map[string]string{}
+	}
+
+	record.details[key] = value
+}
+
+func auditHandler(sinks []AuditSink, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		startTime := time.Now()
+
+		entry := AuditEntry{
+			Time:         startTime,
+			RemoteAddr:   req.RemoteAddr,
+			ForwardedFor: req.Header.Get("X-Forwarded-For"),
+			Method:       req.Method,
+			Path:         req.URL.Path,
+			Mutating:     isMutatingMethod(req.Method),
+		}
+
+		record := &auditRecord{}
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		defer func() {
+			entry.Identity = record.identity
+			entry.Details = record.details
+			entry.Status = recorder.status()
+			entry.Duration = time.Since(startTime)
+
+			for _, sink := range sinks {
+				sink.Record(entry)
+			}
+		}()
+
+		handler(recorder, req.WithContext(context.WithValue(req.Context(), auditRecordKey, record)))
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	return method != "GET" && method != "HEAD" && method != "OPTIONS"
+}
+
+// Captures the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	if recorder.statusCode == 0 {
+		recorder.statusCode = statusCode
+	}
+
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *statusRecorder) Write(bytes []byte) (int, error) {
+	if recorder.statusCode == 0 {
+		recorder.statusCode = http.StatusOK
+	}
+
+	return recorder.ResponseWriter.Write(bytes)
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Gives http.ResponseController access to the original writer.
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+func (recorder *statusRecorder) status() int {
+	if recorder.statusCode == 0 {
+		return http.StatusOK
+	}
+
+	return recorder.statusCode
+}
@@ -0,0 +1,162 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"net/http"
+)
+
+// Identity describes the authenticated caller of an admin route.
+type Identity struct {
+	// Name of the user, token or certificate that was used to authenticate.
+	Name string
+
+	// The authentication scheme, e.g. "basic".
+	Scheme string
+
+	// The roles granted to the caller, see MapRoles.
+	Roles []string
+
+	// The verified client certificate, if the caller authenticated using TLS.
+	Certificate *x509.Certificate
+}
+
+// Returns true, if the identity was granted all of the given roles.
+func (identity Identity) HasRoles(roles ...string) bool {
+	for _, role := range roles {
+		var found bool
+		for _, granted := range identity.Roles {
+			found = found || granted == role
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// An Authenticator verifies the credentials of a request.
+type Authenticator interface {
+	// Returns the identity of the caller. The second return value is false,
+	// if the request does not carry valid credentials.
+	Authenticate(req *http.Request) (Identity, bool)
+}
+
+// Authenticators can implement this interface to send a WWW-Authenticate
+// header to unauthenticated clients.
+type challenger interface {
+	Challenge() string
+}
+
+// Returns the identity of the authenticated caller of the current request.
+func IdentityFromRequest(req *http.Request) (Identity, bool) {
+	identity, ok := req.Context().Value(identityKey).(Identity)
+	return identity, ok
+}
+
+func withIdentity(req *http.Request, identity Identity) *http.Request {
+	if record := auditRecordFromRequest(req); record != nil {
+		record.identity = identity.Name
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), identityKey, identity))
+}
+
+// Protects the given route configs and all of their children with the given
+// authenticator. Requests without valid credentials are rejected with 401.
+func RequireAuthWith(auth Authenticator, configs ...RouteConfig) RouteConfig {
+	guard := &authGuard{auth: auth}
+
+	rc := Use(guard.middleware, configs...)
+	rc.guards = []*authGuard{guard}
+	return rc
+}
+
+// Protects routes with an authenticator. The routes keep a reference
+// to their guards, so the index page can check the callers identity.
+type authGuard struct {
+	auth Authenticator
+}
+
+func (guard *authGuard) middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		identity, ok := guard.auth.Authenticate(req)
+		if !ok {
+			guard.challenge(w)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, withIdentity(req, identity))
+	})
+}
+
+func (guard *authGuard) challenge(w http.ResponseWriter) {
+	if challenger, ok := guard.auth.(challenger); ok && challenger.Challenge() != "" {
+		w.Header().Add("WWW-Authenticate", challenger.Challenge())
+	}
+}
+
+// Verifies a password.
+type passwordVerifier func(password string) bool
+
+// Maps user names to the verifiers of their passwords.
+type credentials map[string]passwordVerifier
+
+type basicAuth struct {
+	credentials func() credentials
+}
+
+func (auth basicAuth) Authenticate(req *http.Request) (Identity, bool) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return Identity{}, false
+	}
+
+	verify := auth.credentials()[user]
+	if verify == nil || !verify(password) {
+		return Identity{}, false
+	}
+
+	return Identity{Name: user, Scheme: "basic"}, true
+}
+
+func (auth basicAuth) Challenge() string {
+	return `Basic realm="Restricted"`
+}
+
+// Creates an authenticator that checks basic auth credentials against a
+// plaintext user and password.
+func PlainCredentials(user, pass string) Authenticator {
+	expected := sha256.Sum256([]byte(pass))
+
+	users := credentials{
+		user: func(password string) bool {
+			actual := sha256.Sum256([]byte(password))
+			return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+		},
+	}
+
+	return basicAuth{credentials: func() credentials { return users }}
+}
+
+// Creates an authenticator that checks basic auth credentials against a map
+// of user names to password hashes. Supported are bcrypt hashes as well as
+// the {SHA} and $apr1$ hashes produced by apaches htpasswd tool.
+func HashedCredentials(users map[string]string) (Authenticator, error) {
+	parsed := credentials{}
+	for user, hash := range users {
+		verifier, err := parsePasswordHash(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed[user] = verifier
+	}
+
+	return basicAuth{credentials: func() credentials { return parsed }}, nil
+}
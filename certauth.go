@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"path"
+)
+
+type certificateAuth struct {
+	roots    *x509.CertPool
+	patterns []string
+}
+
+// Creates an authenticator that accepts TLS client certificates signed by one
+// of the given root certificates. The certificate must match one of the
+// patterns, which are matched against the subjects common name and against
+// the DNS, email and URI subject alternative names. Patterns use the syntax
+// of path.Match, e.g. "*.ops.example.com" or "spiffe://cluster/ns/*/sa/admin".
+// If no pattern is given, every verified certificate is accepted.
+//
+// The name that matched becomes the name of the callers identity, the verified
+// certificate is available in the identity too. The TLS config of the server must
+// request client certificates, e.g. by using tls.VerifyClientCertIfGiven.
+//
+// The roots are required. Falling back to the system roots would accept any
+// certificate issued by a public certificate authority.
+func ClientCertificates(roots *x509.CertPool, patterns ...string) (Authenticator, error) {
+	if roots == nil {
+		return nil, errors.New("no root certificates given")
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return certificateAuth{roots: roots, patterns: patterns}, nil
+}
+
+func (auth certificateAuth) Authenticate(req *http.Request) (Identity, bool) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return Identity{}, false
+	}
+
+	certificate := req.TLS.PeerCertificates[0]
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range req.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certificate.Verify(x509.VerifyOptions{
+		Roots:         auth.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	if err != nil {
+		return Identity{}, false
+	}
+
+	name, ok := auth.match(certificate)
+	if !ok {
+		return Identity{}, false
+	}
+
+	return Identity{Name: name, Scheme: "certificate", Certificate: certificate}, true
+}
+
+// Returns the first name of the certificate that matches one of the patterns.
+func (auth certificateAuth) match(certificate *x509.Certificate) (string, bool) {
+	names := []string{certificate.Subject.CommonName}
+	names = append(names, certificate.DNSNames...)
+	names = append(names, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+
+	if len(auth.patterns) == 0 {
+		return names[0], true
+	}
+
+	for _, pattern := range auth.patterns {
+		for _, name := range names {
+			if matched, _ := path.Match(pattern, name); matched && name != "" {
+				return name, true
+			}
+		}
+	}
+
+	return "", false
+}
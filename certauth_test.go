@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca testCA) issue(t *testing.T, commonName string, usages ...x509.ExtKeyUsage) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func authenticateCertificate(auth Authenticator, cert *x509.Certificate) (Identity, bool) {
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	return auth.Authenticate(req)
+}
+
+func TestClientCertificates(t *testing.T) {
+	ca, otherCA := newTestCA(t), newTestCA(t)
+
+	auth, err := ClientCertificates(ca.pool(), "*.ops.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		ok   bool
+	}{
+		{"valid certificate", ca.issue(t, "alice.ops.example.com", x509.ExtKeyUsageClientAuth), true},
+		{"wrong ca", otherCA.issue(t, "alice.ops.example.com", x509.ExtKeyUsageClientAuth), false},
+		{"pattern mismatch", ca.issue(t, "alice.dev.example.com", x509.ExtKeyUsageClientAuth), false},
+		{"no client auth usage", ca.issue(t, "alice.ops.example.com", x509.ExtKeyUsageServerAuth), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			identity, ok := authenticateCertificate(auth, test.cert)
+			if ok != test.ok {
+				t.Fatalf("expected authenticated=%v, got %v", test.ok, ok)
+			}
+
+			if ok && (identity.Name != "alice.ops.example.com" || identity.Scheme != "certificate" || identity.Certificate != test.cert) {
+				t.Fatalf("unexpected identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestClientCertificatesWithoutPatterns(t *testing.T) {
+	ca := newTestCA(t)
+
+	auth, err := ClientCertificates(ca.pool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity, ok := authenticateCertificate(auth, ca.issue(t, "anyone", x509.ExtKeyUsageClientAuth)); !ok || identity.Name != "anyone" {
+		t.Fatalf("expected certificate to be accepted, got %+v", identity)
+	}
+}
+
+func TestClientCertificatesRequiresRoots(t *testing.T) {
+	if _, err := ClientCertificates(nil); err == nil {
+		t.Fatal("expected an error without root certificates")
+	}
+}
+
+func TestClientCertificatesWithoutCertificate(t *testing.T) {
+	auth, err := ClientCertificates(newTestCA(t).pool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := auth.Authenticate(httptest.NewRequest("GET", "/admin", nil)); ok {
+		t.Fatal("expected request without certificate to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Checks the routes for conflicts. Two routes conflict, if they have the same
+// path and method, or if they accept the same request without one of them
+// being more specific than the other one. In the later case the route that
+// is tried first would silently shadow the other one for some paths.
+func checkRoutes(routes []Route) error {
+	var errs []error
+
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			a, b := routes[i], routes[j]
+			if !methodsOverlap(a.Method, b.Method) || !a.pattern.overlaps(b.pattern) {
+				continue
+			}
+
+			aContainsB := a.pattern.contains(b.pattern)
+			bContainsA := b.pattern.contains(a.pattern)
+
+			switch {
+			case aContainsB && bContainsA:
+				// an explicit method is more specific than a route for all methods.
+				if a.Method == b.Method {
+					errs = append(errs, fmt.Errorf(
+						"route %s is registered more than once", describeRoute(a)))
+				}
+
+			case !aContainsB && !bContainsA:
+				errs = append(errs, fmt.Errorf(
+					"route %s shadows route %s, both match %s and neither is more specific",
+					describeRoute(a), describeRoute(b), a.pattern.commonPath(b.pattern)))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func methodsOverlap(a, b string) bool {
+	return isCompatibleMethod(a, b) || isCompatibleMethod(b, a)
+}
+
+func describeRoute(route Route) string {
+	method := route.Method
+	if method == "" {
+		method = "*"
+	}
+
+	if route.wildcard {
+		return method + " " + route.Path + " (wildcard)"
+	}
+
+	return method + " " + route.Path
+}
+
+// Orders the routes so that the most specific route that matches a request
+// comes first. Routes with an explicit method are tried before routes that
+// accept any method.
+func sortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if cmp := comparePatterns(routes[i].pattern, routes[j].pattern); cmp != 0 {
+			return cmp < 0
+		}
+
+		if rankI, rankJ := methodRank(routes[i].Method), methodRank(routes[j].Method); rankI != rankJ {
+			return rankI < rankJ
+		}
+
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+
+		return routes[i].Path < routes[j].Path
+	})
+}
+
+func methodRank(method string) int {
+	switch method {
+	case "HEAD":
+		// HEAD is also accepted by GET routes, so an explicit HEAD route must be tried first.
+		return 0
+	case "":
+		return 2
+	default:
+		return 1
+	}
+}
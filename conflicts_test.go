@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"strings"
+	"testing"
+)
+
+type testRoute struct {
+	method   string
+	path     string
+	wildcard bool
+}
+
+func newTestRoutes(t *testing.T, routes ...testRoute) []Route {
+	var result []Route
+	for _, route := range routes {
+		pattern, err := parsePattern(route.path, route.wildcard)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result = append(result, Route{Method: route.method, Path: route.path, wildcard: route.wildcard, pattern: pattern})
+	}
+
+	return result
+}
+
+func TestCheckRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []testRoute
+		err    string
+	}{
+		{"different paths", []testRoute{{"GET", "/a", false}, {"GET", "/b", false}}, ""},
+		{"different methods", []testRoute{{"GET", "/a", false}, {"POST", "/a", false}}, ""},
+		{"explicit method and any method", []testRoute{{"GET", "/a", false}, {"", "/a", false}}, ""},
+		{"get and head", []testRoute{{"GET", "/a", false}, {"HEAD", "/a", false}}, ""},
+		{"literal and parameter", []testRoute{{"GET", "/a/b", false}, {"GET", "/a/{x}", false}}, ""},
+		{"parameter and rest", []testRoute{{"GET", "/a/{x}", false}, {"GET", "/a/{x...}", false}}, ""},
+		{"route and wildcard", []testRoute{{"GET", "/a/b", false}, {"GET", "/a", true}}, ""},
+		{"different lengths", []testRoute{{"GET", "/a/{x}", false}, {"GET", "/{y}/b/c", false}}, ""},
+
+		{"same route", []testRoute{{"GET", "/a", false}, {"GET", "/a", false}},
+			"route GET /a is registered more than once"},
+		{"same route for any method", []testRoute{{"", "/a", false}, {"", "/a/", false}},
+			"route * /a is registered more than once"},
+		{"same pattern", []testRoute{{"GET", "/a/{x}", false}, {"GET", "/a/{y}", false}},
+			"route GET /a/{x} is registered more than once"},
+		{"rest and wildcard", []testRoute{{"GET", "/a/{x...}", false}, {"GET", "/a", true}},
+			"route GET /a/{x...} is registered more than once"},
+		{"crossing parameters", []testRoute{{"GET", "/a/{x}", false}, {"GET", "/{y}/b", false}},
+			"route GET /a/{x} shadows route GET /{y}/b, both match /a/b"},
+		{"crossing parameters for any method", []testRoute{{"GET", "/a/{x}", false}, {"", "/{y}/b", false}},
+			"route GET /a/{x} shadows route * /{y}/b, both match /a/b"},
+		{"crossing rest segments", []testRoute{{"GET", "/a/{x...}", false}, {"GET", "/{y}/b/{z...}", false}},
+			"route GET /a/{x...} shadows route GET /{y}/b/{z...}, both match /a/b"},
+		{"crossing wildcard", []testRoute{{"GET", "/{x}/b", false}, {"GET", "/a", true}},
+			"route GET /{x}/b shadows route GET /a (wildcard), both match /a/b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkRoutes(newTestRoutes(t, test.routes...))
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	routes := newTestRoutes(t,
+		testRoute{"", "/", true},
+		testRoute{"GET", "/files/{path...}", false},
+		testRoute{"", "/caches/{name}", false},
+		testRoute{"GET", "/caches/{name}", false},
+		testRoute{"HEAD", "/caches/{name}", false},
+		testRoute{"GET", "/caches/users", false},
+		testRoute{"GET", "/files/{name}", false},
+		testRoute{"GET", "/caches", false},
+	)
+
+	sortRoutes(routes)
+
+	var order []string
+	for _, route := range routes {
+		order = append(order, describeRoute(route))
+	}
+
+	expected := []string{
+		"GET /caches/users",
+		"HEAD /caches/{name}",
+		"GET /caches/{name}",
+		"* /caches/{name}",
+		"GET /caches",
+		"GET /files/{name}",
+		"GET /files/{path...}",
+		"* / (wildcard)",
+	}
+
+	if strings.Join(order, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("expected order\n  %s\ngot\n  %s", strings.Join(expected, "\n  "), strings.Join(order, "\n  "))
+	}
+}
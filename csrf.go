@@ -0,0 +1,146 @@
+package admin
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Non-browser clients can send this header with any value to call routes
+// that are protected against cross site request forgery. Browsers can not
+// send custom headers to other origins without a CORS preflight, which the
+// admin handler never allows.
+const CSRFHeader = "X-Admin-Request"
+
+// Header and form field that can carry a token obtained from CSRFToken.
+const (
+	CSRFTokenHeader    = "X-CSRF-Token"
+	CSRFTokenFormField = "csrf_token"
+)
+
+const csrfTokenValidity = 12 * time.Hour
+
+// random key to sign csrf tokens, valid for the lifetime of the process.
+var csrfKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	return key
+}()
+
+// Disables the protection against cross site request forgery for the
+// route and all of its children.
+func (rc RouteConfig) SkipCSRFCheck() RouteConfig {
+	rc.skipCSRF = true
+	return rc
+}
+
+// Returns a new token that can be embedded into html forms of admin pages
+// to submit them to routes with csrf protection. The token is bound to the
+// credentials of the request and is only accepted together with them.
+func CSRFToken(req *http.Request) string {
+	return csrfTokenAt(time.Now(), csrfBinding(req))
+}
+
+// Hashes the credentials a request carries for the built in authenticators,
+// so a token handed out to one caller is useless with the credentials of
+// another one.
+func csrfBinding(req *http.Request) []byte {
+	hash := sha256.New()
+	hash.Write([]byte(req.Header.Get("Authorization")))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Header.Get(TokenHeader)))
+	hash.Write([]byte{0})
+
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		hash.Write(req.TLS.PeerCertificates[0].Raw)
+	}
+
+	return hash.Sum(nil)
+}
+
+func csrfTokenAt(timestamp time.Time, binding []byte) string {
+	var payload [8]byte
+	binary.BigEndian.PutUint64(payload[:], uint64(timestamp.Unix()))
+
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write(payload[:])
+	mac.Write(binding)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(payload[:]))
+}
+
+func validCSRFToken(token string, req *http.Request) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(decoded) != 8+sha256.Size {
+		return false
+	}
+
+	timestamp := time.Unix(int64(binary.BigEndian.Uint64(decoded[:8])), 0)
+	if time.Since(timestamp) > csrfTokenValidity || time.Until(timestamp) > time.Minute {
+		return false
+	}
+
+	return hmac.Equal([]byte(csrfTokenAt(timestamp, csrfBinding(req))), []byte(token))
+}
+
+// Routes with an explicit method that might change state are protected.
+// Routes that accept any method are left alone, as non-browser clients
+// like the pprof tool post to them.
+func needsCSRFProtection(method string) bool {
+	return method != "" && isMutatingMethod(method)
+}
+
+// Rejects requests that might originate from a foreign web page. A request is
+// accepted if it carries the CSRFHeader, a valid csrf token, or if its Origin
+// or Referer header matches the host of the request.
+func csrfHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !csrfCheckPassed(req) {
+			http.Error(w, "Request rejected, it might be a cross site request forgery. "+
+				"Non-browser clients can send the header "+CSRFHeader+".", http.StatusForbidden)
+
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func csrfCheckPassed(req *http.Request) bool {
+	if req.Header.Get(CSRFHeader) != "" {
+		return true
+	}
+
+	token := req.Header.Get(CSRFTokenHeader)
+	if token == "" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		token = req.PostFormValue(CSRFTokenFormField)
+	}
+
+	if token != "" {
+		return validCSRFToken(token, req)
+	}
+
+	if origin := req.Header.Get("Origin"); origin != "" {
+		return sameHost(origin, req.Host)
+	}
+
+	if referer := req.Header.Get("Referer"); referer != "" {
+		return sameHost(referer, req.Host)
+	}
+
+	return false
+}
+
+func sameHost(rawURL, host string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && parsed.Host != "" && strings.EqualFold(parsed.Host, host)
+}
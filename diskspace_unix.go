@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package admin
+
+import "golang.org/x/sys/unix"
+
+// Returns the disk space in bytes available to unprivileged users.
+func freeDiskSpace(directory string) (int64, bool, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(directory, &stat); err != nil {
+		return 0, false, err
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), true, nil
+}
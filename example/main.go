@@ -11,6 +11,7 @@ import (
 func main() {
 	admin := NewAdminHandler("example", "/admin",
 		WithDefaults(),
+		WithRuntimeMetrics(),
 		WithBuildInfo(BuildInfo{}),
 		WithMetrics(nil),
 
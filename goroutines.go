@@ -0,0 +1,333 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"html/template"
+	"net/http"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A single frame of a goroutines stack.
+type stackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Goroutines with the same state and an identical stack.
+type goroutineGroup struct {
+	Count int
+	State string
+
+	// Range of the time the goroutines are waiting in their state. The
+	// runtime reports wait durations in minutes, and only after a minute.
+	MinWait time.Duration `json:",omitempty"`
+	MaxWait time.Duration `json:",omitempty"`
+
+	LockedToThread bool `json:",omitempty"`
+
+	// The ids of the first goroutines in this group.
+	IDs []int64
+
+	Stack     []stackFrame
+	CreatedBy *stackFrame `json:",omitempty"`
+}
+
+// A single goroutine as parsed from the stack dump.
+type goroutine struct {
+	id             int64
+	state          string
+	wait           time.Duration
+	lockedToThread bool
+	stack          []stackFrame
+	createdBy      *stackFrame
+}
+
+const maxGoroutineIDs = 32
+
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+)[^\[]*\[(.*)\]:$`)
+
+// Returns the stacks of all goroutines as formatted by the runtime.
+func allStacks() []byte {
+	buffer := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buffer, true)
+		if n < len(buffer) {
+			return buffer[:n]
+		}
+
+		buffer = make([]byte, 2*len(buffer))
+	}
+}
+
+// Parses a stack dump as written by runtime.Stack or the 'goroutine'
+// profile with debug=2.
+func parseGoroutines(dump []byte) []goroutine {
+	var result []goroutine
+	var current *goroutine
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(nil, 1<<20)
+
+	// the function of the frame, the next line contains the location.
+	var function string
+	var createdBy bool
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := goroutineHeader.FindStringSubmatch(line); match != nil {
+			result = append(result, parseGoroutineHeader(match[1], match[2]))
+			current = &result[len(result)-1]
+			continue
+		}
+
+		if current == nil || line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "\t") {
+			if function == "" {
+				continue
+			}
+
+			frame := parseStackFrame(function, line)
+			if createdBy {
+				current.createdBy = &frame
+			} else {
+				current.stack = append(current.stack, frame)
+			}
+
+			function = ""
+			continue
+		}
+
+		createdBy = strings.HasPrefix(line, "created by ")
+		if createdBy {
+			// the id of the parent goroutine would prevent grouping.
+			function = strings.TrimPrefix(line, "created by ")
+			if idx := strings.Index(function, " in goroutine "); idx >= 0 {
+				function = function[:idx]
+			}
+
+			continue
+		}
+
+		function = line
+
+		// remove the arguments of the call
+		if strings.HasSuffix(function, ")") {
+			if idx := strings.LastIndexByte(function, '('); idx > 0 {
+				function = function[:idx]
+			}
+		}
+	}
+
+	return result
+}
+
+func parseGoroutineHeader(id, state string) goroutine {
+	parsedID, _ := strconv.ParseInt(id, 10, 64)
+
+	parts := strings.Split(state, ", ")
+	result := goroutine{id: parsedID, state: parts[0]}
+
+	for _, part := range parts[1:] {
+		switch {
+		case part == "locked to thread":
+			result.lockedToThread = true
+
+		case strings.HasSuffix(part, " minutes"):
+			minutes, _ := strconv.Atoi(strings.TrimSuffix(part, " minutes"))
+			result.wait = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	return result
+}
+
+func parseStackFrame(function, location string) stackFrame {
+	location = strings.TrimSpace(location)
+
+	// remove the program counter offset
+	if idx := strings.LastIndex(location, " +0x"); idx >= 0 {
+		location = location[:idx]
+	}
+
+	frame := stackFrame{Function: function, File: location}
+	if idx := strings.LastIndexByte(location, ':'); idx >= 0 {
+		if line, err := strconv.Atoi(location[idx+1:]); err == nil {
+			frame.File, frame.Line = location[:idx], line
+		}
+	}
+
+	return frame
+}
+
+// Groups goroutines by their state and stack, largest groups first.
+func groupGoroutines(goroutines []goroutine) []*goroutineGroup {
+	groups := map[string]*goroutineGroup{}
+
+	var result []*goroutineGroup
+	for _, g := range goroutines {
+		var key strings.Builder
+		key.WriteString(g.state)
+		if g.lockedToThread {
+			key.WriteString(", locked")
+		}
+
+		for _, frame := range g.stack {
+			key.WriteString("\n" + frame.Function + " " + frame.File + ":" + strconv.Itoa(frame.Line))
+		}
+
+		if g.createdBy != nil {
+			key.WriteString("\ncreated by " + g.createdBy.Function + " " + g.createdBy.File + ":" + strconv.Itoa(g.createdBy.Line))
+		}
+
+		group := groups[key.String()]
+		if group == nil {
+			group = &goroutineGroup{
+				State:          g.state,
+				LockedToThread: g.lockedToThread,
+				MinWait:        g.wait,
+				Stack:          g.stack,
+				CreatedBy:      g.createdBy,
+			}
+
+			groups[key.String()] = group
+			result = append(result, group)
+		}
+
+		group.Count++
+
+		if len(group.IDs) < maxGoroutineIDs {
+			group.IDs = append(group.IDs, g.id)
+		}
+
+		if g.wait < group.MinWait {
+			group.MinWait = g.wait
+		}
+
+		if g.wait > group.MaxWait {
+			group.MaxWait = g.wait
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+
+		return result[i].MaxWait > result[j].MaxWait
+	})
+
+	return result
+}
+
+type goroutinesContext struct {
+	Total  int
+	Groups []*goroutineGroup
+}
+
+const goroutinesTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<title>Goroutines</title>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		body {
+			font-family: sans-serif;
+			margin: 2em;
+		}
+
+		summary {
+			cursor: pointer;
+			padding: 0.3em 0;
+		}
+
+		.count {
+			display: inline-block;
+			min-width: 4em;
+			font-weight: bold;
+		}
+
+		.muted {
+			color: #777;
+		}
+
+		pre {
+			background: #f8f8f8;
+			padding: 0.5em;
+			overflow-x: auto;
+		}
+	</style>
+</head>
+<body>
+	<h1>{{ .Total }} goroutines in {{ len .Groups }} groups</h1>
+	{{ range .Groups }}
+		<details>
+			<summary>
+				<span class="count">{{ .Count }}</span>
+				{{ .State }}{{ if .MaxWait }}, waiting {{ if ne .MinWait .MaxWait }}{{ .MinWait }} to {{ end }}{{ .MaxWait }}{{ end }}{{ if .LockedToThread }}, locked to thread{{ end }}
+				{{ if .Stack }}<span class="muted">in {{ (index .Stack 0).Function }}</span>{{ end }}
+			</summary>
+			<pre>{{ range .Stack }}{{ .Function }}
+	{{ .File }}:{{ .Line }}
+{{ end }}{{ with .CreatedBy }}created by {{ .Function }}
+	{{ .File }}:{{ .Line }}
+{{ end }}</pre>
+			<div class="muted">ids {{ range $idx, $id := .IDs }}{{ if $idx }}, {{ end }}{{ $id }}{{ end }}{{ if gt .Count (len .IDs) }}, ...{{ end }}</div>
+		</details>
+	{{ end }}
+</body>
+</html>`
+
+// Serves the stacks of all goroutines, grouped by state and identical stacks
+// and sorted by the size of the group. The groups are served as json at
+// /goroutines and as a html page at /goroutines/view.
+func WithGoroutines() RouteConfig {
+	tmpl, err := template.New("goroutines").Parse(goroutinesTemplate)
+	if err != nil {
+		// should never happen!
+		panic(err)
+	}
+
+	// collecting the stacks stops the world, do not run it in parallel.
+	limit := Limit{Concurrency: 1}
+
+	return RouteConfig{children: []RouteConfig{
+		Describe(
+			"All goroutines, grouped by state and stack, largest groups first.",
+			WithGenericValue("/goroutines", func() []*goroutineGroup {
+				return groupGoroutines(parseGoroutines(allStacks()))
+			})).Limited(limit),
+
+		Describe(
+			"All goroutines, grouped by state and stack, as a collapsible html page.",
+			WithGetHandlerFunc("/goroutines/view", func(w http.ResponseWriter, req *http.Request) {
+				goroutines := parseGoroutines(allStacks())
+
+				templateContext := goroutinesContext{
+					Total:  len(goroutines),
+					Groups: groupGoroutines(goroutines),
+				}
+
+				buffer := &bytes.Buffer{}
+				if err := tmpl.Execute(buffer, templateContext); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/html")
+				w.Write(buffer.Bytes())
+			})).Limited(limit),
+	}}
+}
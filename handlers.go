@@ -2,10 +2,7 @@ package admin
 
 import (
 	"fmt"
-	"github.com/goji/httpauth"
 	"github.com/kardianos/osext"
-	"io"
-	"io/ioutil"
 	"net/http"
 	pprofH "net/http/pprof"
 	"os"
@@ -39,10 +36,16 @@ func WithRedirect(from, to string) RouteConfig {
 	return WithHandler("", from, http.RedirectHandler(to, http.StatusTemporaryRedirect))
 }
 
+// Serves the content of the registry. The format is picked using the Accept
+// header or the 'format' query parameter, supported are json, prometheus
+// and openmetrics. The metrics can be filtered using the query parameters
+// 'prefix' and 'name' (a glob), their type can be selected using 'type'.
+// For json, 'fields' picks single fields like 'count' or 'p99'. Filtered json
+// is indented for reading in a browser, use 'pretty=false' for compact output.
 func WithMetrics(registry MetricsRegistry) RouteConfig {
 	return Describe(
-		"The current content of the MetricsRegistry",
-		WithGenericValue("/metrics", registry))
+		"The current content of the MetricsRegistry, as json or in prometheus format",
+		WithGetHandler("/metrics", metricsHandler(registry)))
 }
 
 func WithForceGC() RouteConfig {
@@ -56,7 +59,8 @@ func WithForceGC() RouteConfig {
 
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(fmt.Sprintf("gc took %s", time.Since(start))))
-		}))
+		})).
+		Limited(Limit{Concurrency: 1, Interval: time.Second, Burst: 3})
 }
 
 var appStartTime = time.Now()
@@ -84,36 +88,8 @@ func WithBuildInfo(buildInfo BuildInfo) RouteConfig {
 		}))
 }
 
-func WithHeapDump() RouteConfig {
-	return Describe(
-		"Creates a snapshot of the processes heap.",
-		WithHandlerFunc("GET", "pprof/heapdump", func(w http.ResponseWriter, req *http.Request) {
-			w.Header().Set("Content-Type", "application/octet-stream")
-
-			// we can not write the heap-dump directly to the network socket,
-			// so write it down to disk first.
-			file, err := ioutil.TempFile("/tmp/", "heapdump")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			// cleanup after ourselves
-			defer os.Remove(file.Name())
-			defer file.Close()
-
-			// write the dump to a file
-			debug.WriteHeapDump(file.Fd())
-
-			// looks good, serve the file.
-			filename := fmt.Sprintf("heapdump-%s.heap", time.Now().Format("20060102-150405"))
-			w.Header().Set("Content-Disposition", "attachment; filename="+filename)
-
-			file.Seek(0, os.SEEK_SET)
-			io.Copy(w, file)
-		}))
-}
-
+// Exposes the handlers of the net/http/pprof package below pprof/,
+// including all named profiles, see WithNamedProfiles.
 func WithPProfHandlers() RouteConfig {
 	rc := RouteConfig{children: []RouteConfig{
 		Describe(
@@ -122,11 +98,13 @@ func WithPProfHandlers() RouteConfig {
 
 		Describe(
 			"Profiles the application. Use with 'go tool pprof http://host/pprof/profile'",
-			WithHandler("GET", "pprof/profile", http.HandlerFunc(pprofH.Profile))),
+			WithHandler("GET", "pprof/profile", http.HandlerFunc(pprofH.Profile)).
+				Limited(Limit{Concurrency: 1})),
 
 		Describe(
 			"Performes a trace of cpu, io and more. Accepts an url parameter 'seconds'",
-			WithHandler("GET", "pprof/trace", http.HandlerFunc(pprofH.Trace))),
+			WithHandler("GET", "pprof/trace", http.HandlerFunc(pprofH.Trace)).
+				Limited(Limit{Concurrency: 1})),
 
 		// symbol can handle post data, register it for GET and POST.
 		Describe(
@@ -139,6 +117,9 @@ func WithPProfHandlers() RouteConfig {
 				w.Header().Set("Content-Type", "application/octet-stream")
 				pprof.WriteHeapProfile(w)
 			})),
+
+		// goroutine, heap, block, mutex and all other named profiles.
+		WithNamedProfiles(),
 	}}
 
 	// also expose the currently used binary - this simplifies profiling.
@@ -159,16 +140,9 @@ func WithEnvironmentVariables() RouteConfig {
 		WithGenericValue("env", os.Environ))
 }
 
+// Protects the given route configs and all of their children using basic auth.
 func RequireAuth(user, pass string, configs ...RouteConfig) RouteConfig {
-	auth := httpauth.SimpleBasicAuth(user, pass)
-
-	var secured []RouteConfig
-	for _, config := range configs {
-		config.Handler = auth(config.Handler)
-		secured = append(secured, config)
-	}
-
-	return RouteConfig{children: secured}
+	return RequireAuthWith(PlainCredentials(user, pass), configs...)
 }
 
 func WithPingPong() RouteConfig {
@@ -0,0 +1,285 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	HealthUp       = "UP"
+	HealthDegraded = "DEGRADED"
+	HealthDown     = "DOWN"
+)
+
+// A named health check of a component.
+type HealthCheck struct {
+	Name string
+
+	// Checks the component. A check must return once the
+	// context is done. Returning an error marks the check as failed.
+	Check func(ctx context.Context) error
+
+	// Maximum duration of the check, defaults to five seconds.
+	Timeout time.Duration
+
+	// A failed critical check marks the service as down, a failing
+	// non-critical check only marks it as degraded.
+	Critical bool
+
+	// The check is also used for the liveness endpoint. Only checks that
+	// can not be fixed without restarting the process should be used
+	// for liveness.
+	Liveness bool
+}
+
+// Result of a single health check.
+type HealthCheckResult struct {
+	Status    string
+	Critical  bool
+	Error     string `json:",omitempty"`
+	Duration  string
+	CheckedAt time.Time
+}
+
+// The aggregated result of all health checks.
+type HealthReport struct {
+	Status string
+	Checks map[string]HealthCheckResult `json:",omitempty"`
+}
+
+// A registry of health checks. The results are cached for the configured
+// interval, so that frequent probes do not overload the checked components.
+type Health struct {
+	cacheInterval time.Duration
+
+	lock   sync.Mutex
+	checks []HealthCheck
+
+	// incremented on each change of the checks to invalidate cached results.
+	version int
+}
+
+// Health checks registered with RegisterHealthCheck.
+var DefaultHealth = NewHealth(5 * time.Second)
+
+// Creates a new registry of health checks.
+func NewHealth(cacheInterval time.Duration) *Health {
+	return &Health{cacheInterval: cacheInterval}
+}
+
+// Registers a health check with the DefaultHealth registry.
+func RegisterHealthCheck(check HealthCheck) {
+	DefaultHealth.Register(check)
+}
+
+// Registers a new health check. A check with the same name is replaced.
+func (health *Health) Register(check HealthCheck) {
+	if check.Timeout <= 0 {
+		check.Timeout = 5 * time.Second
+	}
+
+	health.lock.Lock()
+	defer health.lock.Unlock()
+
+	// copy the checks, reports might still use the previous slice.
+	var checks []HealthCheck
+	for _, existing := range health.checks {
+		if existing.Name != check.Name {
+			checks = append(checks, existing)
+		}
+	}
+
+	health.checks = append(checks, check)
+	health.version++
+}
+
+// Caches the results of the checks selected by a filter, so that each
+// endpoint only runs and waits for its own checks.
+type healthCache struct {
+	health *Health
+	filter func(HealthCheck) bool
+
+	lock    sync.Mutex
+	checks  []HealthCheck
+	results map[string]HealthCheckResult
+	version int
+	checked time.Time
+
+	// closed once the currently running checks have finished.
+	running chan struct{}
+}
+
+func (health *Health) newCache(filter func(HealthCheck) bool) *healthCache {
+	return &healthCache{health: health, filter: filter}
+}
+
+// Returns the results of the selected checks, either from the cache or by
+// running the checks concurrently. Concurrent callers wait for the same run,
+// no lock is held while the checks are running.
+func (cache *healthCache) run() ([]HealthCheck, map[string]HealthCheckResult) {
+	for {
+		cache.health.lock.Lock()
+		checks, version := cache.health.checks, cache.health.version
+		cache.health.lock.Unlock()
+
+		cache.lock.Lock()
+		if cache.results != nil && cache.version == version && time.Since(cache.checked) < cache.health.cacheInterval {
+			checks, results := cache.checks, cache.results
+			cache.lock.Unlock()
+			return checks, results
+		}
+
+		if running := cache.running; running != nil {
+			cache.lock.Unlock()
+			<-running
+			continue
+		}
+
+		running := make(chan struct{})
+		cache.running = running
+		cache.lock.Unlock()
+
+		var selected []HealthCheck
+		for _, check := range checks {
+			if cache.filter(check) {
+				selected = append(selected, check)
+			}
+		}
+
+		results := runHealthChecks(selected)
+
+		cache.lock.Lock()
+		cache.checks, cache.results = selected, results
+		cache.version, cache.checked = version, time.Now()
+		cache.running = nil
+		cache.lock.Unlock()
+
+		close(running)
+
+		return selected, results
+	}
+}
+
+// Runs all checks concurrently.
+func runHealthChecks(checks []HealthCheck) map[string]HealthCheckResult {
+	results := make([]HealthCheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for idx, check := range checks {
+		wg.Add(1)
+		go func(idx int, check HealthCheck) {
+			defer wg.Done()
+			// results are shared, so checks do not use the context of a single request.
+			results[idx] = runHealthCheck(context.Background(), check)
+		}(idx, check)
+	}
+
+	wg.Wait()
+
+	resultsByName := make(map[string]HealthCheckResult, len(results))
+	for idx, check := range checks {
+		resultsByName[check.Name] = results[idx]
+	}
+
+	return resultsByName
+}
+
+func runHealthCheck(ctx context.Context, check HealthCheck) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	startTime := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+
+		done <- check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check did not finish within %s", check.Timeout)
+	}
+
+	result := HealthCheckResult{
+		Status:    HealthUp,
+		Critical:  check.Critical,
+		Duration:  time.Since(startTime).String(),
+		CheckedAt: startTime,
+	}
+
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Builds a report of the checks selected by the filter of the cache.
+func (cache *healthCache) report() HealthReport {
+	checks, results := cache.run()
+
+	report := HealthReport{Status: HealthUp, Checks: map[string]HealthCheckResult{}}
+
+	for _, check := range checks {
+		result := results[check.Name]
+		report.Checks[check.Name] = result
+
+		if result.Status != HealthUp {
+			if check.Critical {
+				report.Status = HealthDown
+			} else if report.Status == HealthUp {
+				report.Status = HealthDegraded
+			}
+		}
+	}
+
+	return report
+}
+
+func (health *Health) handler(filter func(HealthCheck) bool) http.HandlerFunc {
+	cache := health.newCache(filter)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := cache.report()
+
+		status := http.StatusOK
+		if report.Status == HealthDown {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-cache")
+		writeJSON(w, status, report)
+	}
+}
+
+// Serves the results of the health checks at /health/live and /health/ready.
+// The liveness endpoint only runs checks that are marked for liveness, so a
+// slow dependency does not delay it. The readiness endpoint runs all checks.
+// Both respond with 503, if a critical check has failed.
+func WithHealth(health *Health) RouteConfig {
+	allChecks := func(check HealthCheck) bool { return true }
+
+	return RouteConfig{children: []RouteConfig{
+		Describe(
+			"Liveness of the service, fails if the process should be restarted.",
+			WithGetHandler("/health/live", health.handler(func(check HealthCheck) bool {
+				return check.Liveness
+			}))),
+
+		Describe(
+			"Readiness of the service, fails if the service can not handle requests.",
+			WithGetHandler("/health/ready", health.handler(allChecks))),
+	}}
+}
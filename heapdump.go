@@ -0,0 +1,247 @@
+package admin
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configures the heap dump route.
+type HeapDumpOptions struct {
+	// Directory to write the dump to before it is served. On linux, the dump
+	// is kept in an anonymous in-memory file if no directory is given, on other
+	// systems it defaults to the systems temp directory. The file is removed
+	// as soon as it was created, so it does not outlive the process.
+	Directory string
+
+	// Free disk space in bytes that must remain in the directory after the
+	// dump was written. The size of the dump is estimated using the memory
+	// the process has obtained from the operating system.
+	MinFreeSpace int64
+
+	// Memory in bytes that must remain available after an in-memory dump was
+	// written. The available memory is limited by the memory limit of the go
+	// runtime, the memory limit of the cgroup and the memory of the system.
+	MinFreeMemory int64
+}
+
+// Creates a snapshot of the processes heap using debug.WriteHeapDump.
+// See WithHeapDumpOptions.
+func WithHeapDump() RouteConfig {
+	return WithHeapDumpOptions(HeapDumpOptions{})
+}
+
+// Creates a snapshot of the processes heap. The dump is compressed using gzip
+// if the client accepts it. The header X-Heapdump-Size contains the uncompressed
+// size of the dump, the trailer X-Heapdump-Compressed-Size the compressed size.
+//
+// The dump is not streamed through an os.Pipe or written to the network socket
+// directly: WriteHeapDump stops the world while it is writing, so no goroutine
+// could read from the other end and the dump would block forever once the pipe
+// buffer is full. It is written to a file first instead, see HeapDumpOptions.
+// An in-memory file counts against the memory of the process, so the dump is
+// refused if it might exceed the available memory.
+func WithHeapDumpOptions(options HeapDumpOptions) RouteConfig {
+	return Describe(
+		"Creates a snapshot of the processes heap.",
+		WithHandlerFunc("GET", "pprof/heapdump", func(w http.ResponseWriter, req *http.Request) {
+			check := checkHeapDumpSpace
+			if options.Directory == "" && inMemoryHeapDumps {
+				check = checkHeapDumpMemory
+			}
+
+			if err := check(options); err != nil {
+				http.Error(w, err.Error(), http.StatusInsufficientStorage)
+				return
+			}
+
+			file, cleanup, err := createHeapDumpFile(options.Directory)
+			if err != nil {
+				http.Error(w, "Could not create heap dump file: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			defer cleanup()
+
+			startTime := time.Now()
+			debug.WriteHeapDump(file.Fd())
+			duration := time.Since(startTime)
+
+			// WriteHeapDump does not report errors, an empty file is all we can detect.
+			stat, err := file.Stat()
+			if err == nil && stat.Size() == 0 {
+				err = fmt.Errorf("dump is empty")
+			}
+
+			if err != nil {
+				http.Error(w, "Could not write heap dump: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				http.Error(w, "Could not read heap dump: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			filename := fmt.Sprintf("heapdump-%s.heap", time.Now().Format("20060102-150405"))
+
+			header := w.Header()
+			header.Set("Content-Type", "application/octet-stream")
+			header.Set("Content-Disposition", "attachment; filename="+filename)
+			header.Set("X-Heapdump-Size", strconv.FormatInt(stat.Size(), 10))
+			header.Set("X-Heapdump-Duration", duration.String())
+			header.Add("Vary", "Accept-Encoding")
+
+			if !acceptsGzip(req) {
+				header.Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+
+				if _, err := io.Copy(w, file); err != nil {
+					log.Printf("Could not send heap dump: %s", err)
+				}
+
+				return
+			}
+
+			header.Set("Content-Encoding", "gzip")
+			header.Set("Trailer", "X-Heapdump-Compressed-Size")
+
+			counter := &countingWriter{writer: w}
+			writer, _ := gzip.NewWriterLevel(counter, gzip.BestSpeed)
+
+			_, err = io.Copy(writer, file)
+			if err == nil {
+				err = writer.Close()
+			}
+
+			if err != nil {
+				log.Printf("Could not send heap dump: %s", err)
+				return
+			}
+
+			header.Set("X-Heapdump-Compressed-Size", strconv.FormatInt(counter.count, 10))
+		})).
+		Limited(Limit{Concurrency: 1, Interval: time.Minute})
+}
+
+// Refuses to write the dump, if the directory would run out of space.
+func checkHeapDumpSpace(options HeapDumpOptions) error {
+	directory := options.Directory
+	if directory == "" {
+		directory = os.TempDir()
+	}
+
+	free, ok, err := freeDiskSpace(directory)
+	if err != nil {
+		return fmt.Errorf("could not check free disk space: %s", err)
+	}
+
+	if !ok {
+		// checking the free space is not supported on this system
+		return nil
+	}
+
+	required := estimatedHeapDumpSize() + options.MinFreeSpace
+
+	if free < required {
+		return fmt.Errorf("not enough free space in %s, %s required but only %s available",
+			directory, formatBytes(float64(required)), formatBytes(float64(free)))
+	}
+
+	return nil
+}
+
+// Refuses to write the dump into memory, if the process might run out of memory.
+func checkHeapDumpMemory(options HeapDumpOptions) error {
+	size := estimatedHeapDumpSize()
+
+	available, ok := availableMemory(size)
+	if !ok {
+		// checking the available memory is not supported on this system
+		return nil
+	}
+
+	if required := size + options.MinFreeMemory; available < required {
+		return fmt.Errorf("not enough free memory for an in-memory heap dump, %s required but only %s available. "+
+			"Configure a directory to write the dump to disk",
+			formatBytes(float64(required)), formatBytes(float64(available)))
+	}
+
+	return nil
+}
+
+// The dump has about the size of the memory the
+// process has obtained from the operating system.
+func estimatedHeapDumpSize() int64 {
+	samples := []metrics.Sample{{Name: "/memory/classes/total:bytes"}}
+	metrics.Read(samples)
+
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return int64(samples[0].Value.Uint64())
+}
+
+// Creates a file in the given directory and returns a function
+// to close and remove it again.
+func createTempHeapDumpFile(directory string) (*os.File, func(), error) {
+	if directory == "" {
+		directory = os.TempDir()
+	}
+
+	file, err := os.CreateTemp(directory, "heapdump")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// on unix systems the open file stays usable after removing it, so it
+	// is cleaned up even if the process dies. Other systems refuse to remove
+	// an open file, we clean up after serving the dump.
+	if err := os.Remove(file.Name()); err != nil {
+		return file, func() {
+			file.Close()
+			os.Remove(file.Name())
+		}, nil
+	}
+
+	return file, func() { file.Close() }, nil
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, encoding := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Counts the bytes written to the underlying writer.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (counter *countingWriter) Write(bytes []byte) (int, error) {
+	n, err := counter.writer.Write(bytes)
+	counter.count += int64(n)
+	return n, err
+}
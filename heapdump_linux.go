@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const inMemoryHeapDumps = true
+
+// Without a directory, the dump is written to an anonymous in-memory file.
+// It does not need any disk space and works with a read only file system.
+func createHeapDumpFile(directory string) (*os.File, func(), error) {
+	if directory != "" {
+		return createTempHeapDumpFile(directory)
+	}
+
+	fd, err := unix.MemfdCreate("heapdump", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := os.NewFile(uintptr(fd), "heapdump")
+	return file, func() { file.Close() }, nil
+}
+
+// Returns the memory in bytes the process can still allocate, given that it
+// currently uses the given amount. The memory is limited by the memory limit
+// of the go runtime, the limit of the cgroup and the memory the system has available.
+func availableMemory(used int64) (int64, bool) {
+	limit := debug.SetMemoryLimit(-1)
+	if cgroupLimit, ok := cgroupMemoryLimit(); ok && cgroupLimit < limit {
+		limit = cgroupLimit
+	}
+
+	available := int64(math.MaxInt64)
+	if limit != math.MaxInt64 {
+		available = limit - used
+	}
+
+	if systemAvailable, ok := systemAvailableMemory(); ok && systemAvailable < available {
+		available = systemAvailable
+	}
+
+	return available, available != math.MaxInt64
+}
+
+// Reads the memory limit of the cgroup v2 or v1 the process belongs to.
+func cgroupMemoryLimit() (int64, bool) {
+	paths := []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"}
+
+	// the process might be in a nested group, try that one first.
+	if content, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			parts := strings.SplitN(line, ":", 3)
+			if len(parts) != 3 || parts[2] == "/" {
+				continue
+			}
+
+			switch {
+			case parts[0] == "0" && parts[1] == "":
+				paths = append([]string{"/sys/fs/cgroup" + parts[2] + "/memory.max"}, paths...)
+
+			case parts[1] == "memory":
+				paths = append([]string{"/sys/fs/cgroup/memory" + parts[2] + "/memory.limit_in_bytes"}, paths...)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		// "max" means there is no limit
+		limit, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		return limit, err == nil
+	}
+
+	return 0, false
+}
+
+// Reads MemAvailable from /proc/meminfo.
+func systemAvailableMemory() (int64, bool) {
+	content, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemAvailable:" && fields[2] == "kB" {
+			kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+			return kilobytes * 1024, err == nil
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package admin
+
+import "os"
+
+const inMemoryHeapDumps = false
+
+func createHeapDumpFile(directory string) (*os.File, func(), error) {
+	return createTempHeapDumpFile(directory)
+}
+
+// Dumps are never kept in memory on this system.
+func availableMemory(used int64) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,421 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/pprof/profile"
+	"html/template"
+	"net/http"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A named heap profile.
+type heapSnapshot struct {
+	Name         string
+	Time         time.Time
+	InuseBytes   int64
+	InuseObjects int64
+
+	profile *profile.Profile
+}
+
+// Keeps a bounded number of heap snapshots by name.
+type heapSnapshots struct {
+	capacity int
+
+	lock      sync.Mutex
+	snapshots []*heapSnapshot
+}
+
+var validSnapshotName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Runs the garbage collector and takes a heap profile of the live objects.
+func takeHeapSnapshot(name string) (*heapSnapshot, error) {
+	runtime.GC()
+
+	var buffer bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&buffer, 0); err != nil {
+		return nil, err
+	}
+
+	parsed, err := profile.Parse(&buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &heapSnapshot{Name: name, Time: time.Now(), profile: parsed}
+
+	bytesIdx, objectsIdx := sampleIndex(parsed, "inuse_space"), sampleIndex(parsed, "inuse_objects")
+	for _, sample := range parsed.Sample {
+		if bytesIdx >= 0 {
+			snapshot.InuseBytes += sample.Value[bytesIdx]
+		}
+
+		if objectsIdx >= 0 {
+			snapshot.InuseObjects += sample.Value[objectsIdx]
+		}
+	}
+
+	return snapshot, nil
+}
+
+func sampleIndex(p *profile.Profile, sampleType string) int {
+	for idx, valueType := range p.SampleType {
+		if valueType.Type == sampleType {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// Adds a snapshot, replacing one with the same name, and evicts the oldest snapshots.
+func (snapshots *heapSnapshots) add(snapshot *heapSnapshot) {
+	snapshots.lock.Lock()
+	defer snapshots.lock.Unlock()
+
+	var result []*heapSnapshot
+	for _, existing := range snapshots.snapshots {
+		if existing.Name != snapshot.Name {
+			result = append(result, existing)
+		}
+	}
+
+	result = append(result, snapshot)
+	if len(result) > snapshots.capacity {
+		result = result[len(result)-snapshots.capacity:]
+	}
+
+	snapshots.snapshots = result
+}
+
+func (snapshots *heapSnapshots) remove(name string) bool {
+	snapshots.lock.Lock()
+	defer snapshots.lock.Unlock()
+
+	for idx, snapshot := range snapshots.snapshots {
+		if snapshot.Name == name {
+			snapshots.snapshots = append(snapshots.snapshots[:idx:idx], snapshots.snapshots[idx+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (snapshots *heapSnapshots) get(name string) *heapSnapshot {
+	snapshots.lock.Lock()
+	defer snapshots.lock.Unlock()
+
+	for _, snapshot := range snapshots.snapshots {
+		if snapshot.Name == name {
+			return snapshot
+		}
+	}
+
+	return nil
+}
+
+// Returns all snapshots, oldest first.
+func (snapshots *heapSnapshots) list() []*heapSnapshot {
+	snapshots.lock.Lock()
+	defer snapshots.lock.Unlock()
+
+	return append([]*heapSnapshot{}, snapshots.snapshots...)
+}
+
+func (snapshots *heapSnapshots) handleCreate(w http.ResponseWriter, req *http.Request) {
+	name := req.FormValue("name")
+	if name == "" {
+		name = time.Now().UTC().Format("20060102T150405Z")
+	}
+
+	if !validSnapshotName.MatchString(name) {
+		http.Error(w, "The name may only contain letters, digits, '.', '_' and '-'", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := takeHeapSnapshot(name)
+	if err != nil {
+		http.Error(w, "Could not take heap snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	snapshots.add(snapshot)
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+func (snapshots *heapSnapshots) handleDownload(w http.ResponseWriter, req *http.Request) {
+	snapshot := snapshots.get(PathParam(req, "name"))
+	if snapshot == nil {
+		http.Error(w, "Unknown snapshot", http.StatusNotFound)
+		return
+	}
+
+	writeProfile(w, snapshot.profile, "heap-"+snapshot.Name+".pb.gz")
+}
+
+func (snapshots *heapSnapshots) handleDelete(w http.ResponseWriter, req *http.Request) {
+	if !snapshots.remove(PathParam(req, "name")) {
+		http.Error(w, "Unknown snapshot", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Computes the difference from the base to the target snapshot. Responds with
+// an error and returns nil, if a snapshot does not exist.
+func (snapshots *heapSnapshots) delta(w http.ResponseWriter, req *http.Request) *profile.Profile {
+	base, target := snapshots.get(PathParam(req, "base")), snapshots.get(PathParam(req, "target"))
+	if base == nil || target == nil {
+		http.Error(w, "Unknown snapshot", http.StatusNotFound)
+		return nil
+	}
+
+	negated := base.profile.Copy()
+	negated.Scale(-1)
+
+	delta, err := profile.Merge([]*profile.Profile{target.profile, negated})
+	if err != nil {
+		http.Error(w, "Could not compute delta: "+err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	return delta
+}
+
+func (snapshots *heapSnapshots) handleDiff(w http.ResponseWriter, req *http.Request) {
+	if delta := snapshots.delta(w, req); delta != nil {
+		filename := fmt.Sprintf("heap-%s-%s.pb.gz", PathParam(req, "base"), PathParam(req, "target"))
+		writeProfile(w, delta, filename)
+	}
+}
+
+func writeProfile(w http.ResponseWriter, p *profile.Profile, filename string) {
+	var buffer bytes.Buffer
+	if err := p.Write(&buffer); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	w.Write(buffer.Bytes())
+}
+
+// The change of the allocations at a single allocation site.
+type allocationSite struct {
+	Function string
+	File     string
+	Line     int64
+
+	// Change of each sample type of the profile.
+	Deltas []int64
+}
+
+// Sums the values of the delta profile by the location of the allocation.
+func allocationSites(delta *profile.Profile) []*allocationSite {
+	sites := map[string]*allocationSite{}
+
+	var result []*allocationSite
+	for _, sample := range delta.Sample {
+		site := &allocationSite{Function: "unknown"}
+		if len(sample.Location) > 0 && len(sample.Location[0].Line) > 0 {
+			// the first line is the innermost one of inlined functions.
+			line := sample.Location[0].Line[0]
+			if line.Function != nil {
+				site.Function, site.File = line.Function.Name, line.Function.Filename
+			}
+
+			site.Line = line.Line
+		}
+
+		key := fmt.Sprintf("%s %s:%d", site.Function, site.File, site.Line)
+		if existing := sites[key]; existing != nil {
+			site = existing
+		} else {
+			site.Deltas = make([]int64, len(delta.SampleType))
+			sites[key] = site
+			result = append(result, site)
+		}
+
+		for idx, value := range sample.Value {
+			site.Deltas[idx] += value
+		}
+	}
+
+	return result
+}
+
+type heapDiffContext struct {
+	Base, Target string
+	SampleTypes  []string
+	SortedBy     string
+	Sites        []*allocationSite
+	Formatters   []func(int64) string
+}
+
+const heapDiffTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<title>Heap growth from {{ .Base }} to {{ .Target }}</title>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<style>
+		body {
+			font-family: sans-serif;
+			margin: 2em;
+		}
+
+		th, td {
+			padding: 0.2em 1em 0.2em 0;
+			text-align: left;
+			vertical-align: top;
+		}
+
+		.number {
+			text-align: right;
+			white-space: nowrap;
+		}
+
+		.muted {
+			color: #777;
+			font-size: small;
+		}
+	</style>
+</head>
+<body>
+	<h1>Heap growth from {{ .Base }} to {{ .Target }}</h1>
+	<p class="muted">Allocation sites that grew the most by {{ .SortedBy }}. Download the <a href="../{{ .Target }}">delta profile</a> for 'go tool pprof'.</p>
+	<table>
+		<tr>
+			<th>Allocation site</th>
+			{{ range .SampleTypes }}<th class="number"><a href="?sample={{ . }}">{{ . }}</a></th>{{ end }}
+		</tr>
+		{{ range $site := .Sites }}
+			<tr>
+				<td>{{ $site.Function }}<div class="muted">{{ $site.File }}:{{ $site.Line }}</div></td>
+				{{ range $idx, $value := $site.Deltas }}<td class="number">{{ call (index $.Formatters $idx) $value }}</td>{{ end }}
+			</tr>
+		{{ end }}
+	</table>
+</body>
+</html>`
+
+// Takes named heap snapshots and computes the difference between two of them,
+// to find memory leaks. Snapshots are taken using POST /heap/snapshots with an
+// optional 'name' and downloaded at /heap/snapshots/{name}. The delta profile
+// between two snapshots is available in pprof format at /heap/diff/{base}/{target},
+// the allocation sites that grew the most are shown at /heap/diff/{base}/{target}/top.
+// At most 'capacity' snapshots are kept, older ones are discarded.
+func WithHeapSnapshots(capacity int) RouteConfig {
+	if capacity < 2 {
+		capacity = 2
+	}
+
+	snapshots := &heapSnapshots{capacity: capacity}
+
+	tmpl, err := template.New("heapDiff").Parse(heapDiffTemplate)
+	if err != nil {
+		// should never happen!
+		panic(err)
+	}
+
+	return RouteConfig{children: []RouteConfig{
+		Describe(
+			"The named heap snapshots, oldest first.",
+			WithGenericValue("/heap/snapshots", snapshots.list)),
+
+		Describe(
+			"Takes a heap snapshot. Accepts an url parameter 'name'.",
+			WithHandlerFunc("POST", "/heap/snapshots", snapshots.handleCreate)).
+			Limited(Limit{Concurrency: 1}),
+
+		Describe(
+			"Downloads a heap snapshot in pprof format.",
+			WithGetHandlerFunc("/heap/snapshots/{name}", snapshots.handleDownload)),
+
+		Describe(
+			"Deletes a heap snapshot.",
+			WithHandlerFunc("DELETE", "/heap/snapshots/{name}", snapshots.handleDelete)),
+
+		Describe(
+			"The difference between two heap snapshots in pprof format.",
+			WithGetHandlerFunc("/heap/diff/{base}/{target}", snapshots.handleDiff)),
+
+		Describe(
+			"The allocation sites that grew the most between two heap snapshots.",
+			WithGetHandlerFunc("/heap/diff/{base}/{target}/top", func(w http.ResponseWriter, req *http.Request) {
+				delta := snapshots.delta(w, req)
+				if delta == nil {
+					return
+				}
+
+				sortedBy := req.FormValue("sample")
+				if sortedBy == "" {
+					sortedBy = "inuse_space"
+				}
+
+				sortIdx := sampleIndex(delta, sortedBy)
+				if sortIdx < 0 {
+					http.Error(w, fmt.Sprintf("Unknown sample type %q", sortedBy), http.StatusBadRequest)
+					return
+				}
+
+				count := 20
+				if n, err := strconv.Atoi(req.FormValue("n")); err == nil && n > 0 {
+					count = n
+				}
+
+				sites := allocationSites(delta)
+				sort.SliceStable(sites, func(i, j int) bool {
+					return sites[i].Deltas[sortIdx] > sites[j].Deltas[sortIdx]
+				})
+
+				if len(sites) > count {
+					sites = sites[:count]
+				}
+
+				templateContext := heapDiffContext{
+					Base:     PathParam(req, "base"),
+					Target:   PathParam(req, "target"),
+					SortedBy: sortedBy,
+					Sites:    sites,
+				}
+
+				for _, sampleType := range delta.SampleType {
+					templateContext.SampleTypes = append(templateContext.SampleTypes, sampleType.Type)
+
+					format := func(value int64) string { return fmt.Sprintf("%+d", value) }
+					if sampleType.Unit == "bytes" {
+						format = func(value int64) string {
+							if value < 0 {
+								return "-" + formatBytes(float64(-value))
+							}
+
+							return "+" + formatBytes(float64(value))
+						}
+					}
+
+					templateContext.Formatters = append(templateContext.Formatters, format)
+				}
+
+				buffer := &bytes.Buffer{}
+				if err := tmpl.Execute(buffer, templateContext); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/html")
+				w.Write(buffer.Bytes())
+			})),
+	}}
+}
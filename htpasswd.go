@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+)
+
+// Parses a password hash and returns a function to verify passwords against it.
+func parsePasswordHash(hash string) (passwordVerifier, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash: %s", err)
+		}
+
+		return func(password string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+		}, nil
+
+	case strings.HasPrefix(hash, "{SHA}"):
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "{SHA}"))
+		if err != nil || len(expected) != sha1.Size {
+			return nil, fmt.Errorf("invalid {SHA} hash")
+		}
+
+		return func(password string) bool {
+			actual := sha1.Sum([]byte(password))
+			return subtle.ConstantTimeCompare(expected, actual[:]) == 1
+		}, nil
+
+	case strings.HasPrefix(hash, apr1Magic):
+		salt := strings.TrimPrefix(hash, apr1Magic)
+		if idx := strings.IndexByte(salt, '$'); idx >= 0 {
+			salt = salt[:idx]
+		} else {
+			return nil, fmt.Errorf("invalid $apr1$ hash")
+		}
+
+		return func(password string) bool {
+			actual := apr1Hash(password, salt)
+			return subtle.ConstantTimeCompare([]byte(hash), []byte(actual)) == 1
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported password hash, expected bcrypt, {SHA} or $apr1$")
+	}
+}
+
+const apr1Magic = "$apr1$"
+
+// Computes the apache specific variant of the md5 crypt algorithm.
+func apr1Hash(password, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	pw := []byte(password)
+
+	alternate := md5.New()
+	alternate.Write(pw)
+	alternate.Write([]byte(salt))
+	alternate.Write(pw)
+	alternateSum := alternate.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(pw)
+	ctx.Write([]byte(apr1Magic))
+	ctx.Write([]byte(salt))
+
+	for n := len(pw); n > 0; n -= md5.Size {
+		if n > md5.Size {
+			ctx.Write(alternateSum)
+		} else {
+			ctx.Write(alternateSum[:n])
+		}
+	}
+
+	for n := len(pw); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+
+	final := ctx.Sum(nil)
+
+	// stretch the hash to slow down brute force attacks
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+
+		if i&1 != 0 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+
+		final = round.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	var encoded bytes.Buffer
+	encode := func(value uint, count int) {
+		for ; count > 0; count-- {
+			encoded.WriteByte(itoa64[value&0x3f])
+			value >>= 6
+		}
+	}
+
+	encode(uint(final[0])<<16|uint(final[6])<<8|uint(final[12]), 4)
+	encode(uint(final[1])<<16|uint(final[7])<<8|uint(final[13]), 4)
+	encode(uint(final[2])<<16|uint(final[8])<<8|uint(final[14]), 4)
+	encode(uint(final[3])<<16|uint(final[9])<<8|uint(final[15]), 4)
+	encode(uint(final[4])<<16|uint(final[10])<<8|uint(final[5]), 4)
+	encode(uint(final[11]), 2)
+
+	return apr1Magic + salt + "$" + encoded.String()
+}
+
+// Creates an authenticator that checks basic auth credentials against the
+// users in an apache htpasswd file. The file is checked for modifications
+// at most once per reload interval and reloaded if it has changed, so that
+// credentials can be rotated without restarting the process. A reload
+// interval of zero disables reloading.
+//
+// If a reload fails, the previously loaded users stay valid.
+func HtpasswdFile(path string, reloadInterval time.Duration) (Authenticator, error) {
+	file, err := newReloadingFile(path, reloadInterval, parseHtpasswd)
+	if err != nil {
+		return nil, err
+	}
+
+	return basicAuth{credentials: func() credentials { return file.get().(credentials) }}, nil
+}
+
+func parseHtpasswd(content []byte) (interface{}, error) {
+	users := credentials{}
+
+	err := parseColonSeparated(content, func(user, hash string) error {
+		verifier, err := parsePasswordHash(hash)
+		if err != nil {
+			return err
+		}
+
+		users[user] = verifier
+		return nil
+	})
+
+	return users, err
+}
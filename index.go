@@ -1,9 +1,30 @@
 package admin
 
 type link struct {
+	Method      string
 	Name        string
 	Path        string
 	Description string
+
+	// The path contains parameters and can not be linked directly.
+	Placeholder bool
+
+	// The route accepts POST requests and is shown as a form.
+	Form bool
+
+	// The route only accepts methods like PUT or DELETE, that
+	// neither a link nor a form can send.
+	Unlinkable bool
+
+	// The route requires authentication.
+	Protected bool
+
+	// The roles required to call this route.
+	Roles string
+
+	// Token to submit the form to a route with csrf protection. Only
+	// set if the caller passes the guards of the route.
+	CSRFToken string
 }
 
 type linkSlice []link
@@ -12,6 +33,10 @@ func (p linkSlice) Len() int {
 	return len(p)
 }
 func (p linkSlice) Less(i, j int) bool {
+	if p[i].Path == p[j].Path {
+		return p[i].Method < p[j].Method
+	}
+
 	return p[i].Path < p[j].Path
 }
 func (p linkSlice) Swap(i, j int) {
@@ -21,6 +46,9 @@ func (p linkSlice) Swap(i, j int) {
 type indexContext struct {
 	Links   []link
 	AppName string
+
+	// Some routes are not shown, because the user lacks the required roles.
+	HiddenRoutes bool
 }
 
 const indexTemplate = `
@@ -48,11 +76,31 @@ const indexTemplate = `
 		<table>
 			{{ range $link := .Links }}
 				<tr>
-					<td style="padding-right:1.5em"><a href='{{ $link.Path }}'>{{ $link.Name }}</a></td>
+					<td style="padding-right:1em"><small class="text-muted">{{ $link.Method }}</small></td>
+					<td style="padding-right:1.5em">
+						{{ if $link.Placeholder }}
+							<span class="text-muted" title="This path contains parameters">{{ $link.Name }}</span>
+						{{ else if $link.Unlinkable }}
+							<span class="text-muted" title="Send a {{ $link.Method }} request to call this route">{{ $link.Name }}</span>
+						{{ else if $link.Form }}
+							<form method="post" action="{{ $link.Path }}" style="display:inline">
+								{{ if $link.CSRFToken }}<input type="hidden" name="csrf_token" value="{{ $link.CSRFToken }}">{{ end }}
+								<button type="submit" class="btn btn-link" style="padding:0">{{ $link.Name }}</button>
+							</form>
+						{{ else }}
+							<a href='{{ $link.Path }}'>{{ $link.Name }}</a>
+						{{ end }}
+					</td>
+					<td style="padding-right:0.5em">{{ if $link.Protected }}<span title="Requires authentication{{ if $link.Roles }} and the roles {{ $link.Roles }}{{ end }}">&#128274;</span>{{ end }}</td>
 					<td>{{ $link.Description }}</td>
 				</tr>
 			{{ end }}
 		</table>
+		{{ if .HiddenRoutes }}
+			<p class="text-muted" style="margin-top:1em">
+				Some routes are hidden because you lack the required roles. <a href="?login">Sign in</a> to see them.
+			</p>
+		{{ end }}
 	</div>
 </body>
 </html>`
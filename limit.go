@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits the execution of an expensive route.
+type Limit struct {
+	// Maximum number of concurrent executions. Zero means unlimited.
+	Concurrency int
+
+	// The route can be called once per interval, with short bursts of up
+	// to Burst calls. Zero means no rate limit.
+	Interval time.Duration
+	Burst    int
+}
+
+// Limits how often the route and each of its children can be called.
+// Every route gets its own limiter. A limit of a parent config replaces
+// the limits of its children, so that the default limits of the built-in
+// routes can be changed. Use the zero Limit to remove all limits.
+//
+// Calls exceeding the limit are rejected with 429 and a Retry-After header.
+func (rc RouteConfig) Limited(limit Limit) RouteConfig {
+	rc.limit = &limit
+	return rc
+}
+
+type limiter struct {
+	limit     Limit
+	semaphore chan struct{}
+
+	lock       sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newLimiter(limit Limit) *limiter {
+	l := &limiter{limit: limit, tokens: float64(limit.burst()), lastRefill: time.Now()}
+	if limit.Concurrency > 0 {
+		l.semaphore = make(chan struct{}, limit.Concurrency)
+	}
+
+	return l
+}
+
+func (limit Limit) burst() int {
+	if limit.Burst < 1 {
+		return 1
+	}
+
+	return limit.Burst
+}
+
+func (limit Limit) isZero() bool {
+	return limit.Concurrency <= 0 && limit.Interval <= 0
+}
+
+// Takes a token from the bucket. If no token is available, the time
+// until the next token becomes available is returned.
+func (l *limiter) takeToken() (time.Duration, bool) {
+	if l.limit.Interval <= 0 {
+		return 0, true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.lastRefill)) / float64(l.limit.Interval)
+	l.tokens = math.Min(l.tokens, float64(l.limit.burst()))
+	l.lastRefill = now
+
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) * float64(l.limit.Interval)), false
+	}
+
+	l.tokens--
+	return 0, true
+}
+
+func (l *limiter) handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if l.semaphore != nil {
+			select {
+			case l.semaphore <- struct{}{}:
+				defer func() { <-l.semaphore }()
+
+			default:
+				tooManyRequests(w, time.Second, "Too many concurrent calls to this route")
+				return
+			}
+		}
+
+		if wait, ok := l.takeToken(); !ok {
+			tooManyRequests(w, wait, "This route was called too often")
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, message+", retry in "+strconv.Itoa(seconds)+"s", http.StatusTooManyRequests)
+}
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"sync"
+	"time"
+)
+
+// Calls a function once per interval in a background goroutine.
+type backgroundLoop struct {
+	interval time.Duration
+	tick     func()
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopped   chan struct{}
+}
+
+func newBackgroundLoop(interval time.Duration, tick func()) *backgroundLoop {
+	return &backgroundLoop{interval: interval, tick: tick, stopped: make(chan struct{})}
+}
+
+// Starts the loop, the function is called right away.
+// Starting the loop again has no effect.
+func (loop *backgroundLoop) start() {
+	loop.startOnce.Do(func() { go loop.run() })
+}
+
+// Stops the loop. A running call of the function is not
+// interrupted, but it can watch the done channel.
+func (loop *backgroundLoop) stop() {
+	loop.stopOnce.Do(func() { close(loop.stopped) })
+}
+
+// Returns a channel that is closed once the loop was stopped.
+func (loop *backgroundLoop) done() <-chan struct{} {
+	return loop.stopped
+}
+
+func (loop *backgroundLoop) run() {
+	ticker := time.NewTicker(loop.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-loop.stopped:
+			return
+		default:
+		}
+
+		loop.tick()
+
+		select {
+		case <-ticker.C:
+		case <-loop.stopped:
+			return
+		}
+	}
+}
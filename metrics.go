@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// Registries that can be iterated, like the ones of go-metrics.
+type iterableRegistry interface {
+	Each(func(name string, metric interface{}))
+}
+
+// A healthcheck as registered in go-metrics.
+type metricsHealthcheck interface {
+	Check()
+	Error() error
+}
+
+type metricsHealthcheckResult struct {
+	Name    string
+	Healthy bool
+	Error   string `json:",omitempty"`
+}
+
+// Calls the callback for all metrics of the registry, sorted by name.
+// Returns false, if the registry can not be iterated.
+func eachMetric(registry MetricsRegistry, callback func(name string, metric interface{})) bool {
+	iterable, ok := registry.(iterableRegistry)
+	if !ok {
+		return false
+	}
+
+	metrics := map[string]interface{}{}
+	iterable.Each(func(name string, metric interface{}) {
+		metrics[name] = metric
+	})
+
+	var names []string
+	for name := range metrics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		callback(name, metrics[name])
+	}
+
+	return true
+}
+
+// Runs the healthchecks of the registry and shows the status of each one.
+// Responds with 503, if any of the checks has failed.
+func WithMetricsHealthchecks(registry MetricsRegistry) RouteConfig {
+	return Describe(
+		"Runs the healthchecks of the MetricsRegistry. Fails if any check is unhealthy.",
+		WithGetHandlerFunc("/metrics/health", func(w http.ResponseWriter, req *http.Request) {
+			registry.RunHealthchecks()
+
+			response := struct {
+				Healthy bool
+				Checks  []metricsHealthcheckResult
+			}{Healthy: true, Checks: []metricsHealthcheckResult{}}
+
+			iterable := eachMetric(registry, func(name string, metric interface{}) {
+				if check, ok := metric.(metricsHealthcheck); ok {
+					result := metricsHealthcheckResult{Name: name, Healthy: true}
+					if err := check.Error(); err != nil {
+						result.Healthy = false
+						result.Error = err.Error()
+					}
+
+					response.Healthy = response.Healthy && result.Healthy
+					response.Checks = append(response.Checks, result)
+				}
+			})
+
+			if !iterable {
+				http.Error(w, "The MetricsRegistry does not support iteration", http.StatusInternalServerError)
+				return
+			}
+
+			status := http.StatusOK
+			if !response.Healthy {
+				status = http.StatusServiceUnavailable
+			}
+
+			writeJSON(w, status, response)
+		}))
+}
+
+// The interfaces of the go-metrics types. The checks are ordered, timers must
+// be checked before histograms and meters before counters.
+type metricsTimer interface {
+	metricsHistogram
+	Rate1() float64
+}
+
+type metricsHistogram interface {
+	Count() int64
+	Min() int64
+	Max() int64
+	Mean() float64
+	StdDev() float64
+	Percentiles([]float64) []float64
+}
+
+type metricsMeter interface {
+	Count() int64
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+	RateMean() float64
+}
+
+type metricsCounter interface {
+	Count() int64
+}
+
+type metricsGauge interface {
+	Value() int64
+}
+
+type metricsGaugeFloat64 interface {
+	Value() float64
+}
+
+const (
+	counterMetric   = "counter"
+	gaugeMetric     = "gauge"
+	meterMetric     = "meter"
+	histogramMetric = "histogram"
+	timerMetric     = "timer"
+)
+
+// The percentiles reported for histograms and timers.
+var metricPercentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// A consistent snapshot of the values of a single metric.
+type metricSnapshot struct {
+	kind string
+
+	// the value of counters and gauges
+	value float64
+
+	count        int64
+	min, max     int64
+	mean, stddev float64
+	percentiles  []float64
+
+	// 1m, 5m, 15m and mean rate of meters and timers
+	rates [4]float64
+}
+
+// Reads the values of a go-metrics metric. Returns false, if the metric is not
+// one of the supported types.
+func readMetric(metric interface{}) (metricSnapshot, bool) {
+	// take a snapshot first, so all values are consistent.
+	if snapshot := reflect.ValueOf(metric).MethodByName("Snapshot"); snapshot.IsValid() && snapshot.Type().NumIn() == 0 && snapshot.Type().NumOut() == 1 {
+		metric = snapshot.Call(nil)[0].Interface()
+	}
+
+	switch metric := metric.(type) {
+	case metricsTimer:
+		snapshot := readHistogram(metric)
+		snapshot.kind = timerMetric
+		if meter, ok := metric.(metricsMeter); ok {
+			snapshot.rates = readRates(meter)
+		}
+
+		return snapshot, true
+
+	case metricsHistogram:
+		return readHistogram(metric), true
+
+	case metricsMeter:
+		return metricSnapshot{kind: meterMetric, count: metric.Count(), rates: readRates(metric)}, true
+
+	case metricsCounter:
+		return metricSnapshot{kind: counterMetric, count: metric.Count(), value: float64(metric.Count())}, true
+
+	case metricsGauge:
+		return metricSnapshot{kind: gaugeMetric, value: float64(metric.Value())}, true
+
+	case metricsGaugeFloat64:
+		return metricSnapshot{kind: gaugeMetric, value: metric.Value()}, true
+	}
+
+	return metricSnapshot{}, false
+}
+
+func readHistogram(histogram metricsHistogram) metricSnapshot {
+	return metricSnapshot{
+		kind:        histogramMetric,
+		count:       histogram.Count(),
+		min:         histogram.Min(),
+		max:         histogram.Max(),
+		mean:        histogram.Mean(),
+		stddev:      histogram.StdDev(),
+		percentiles: histogram.Percentiles(metricPercentiles),
+	}
+}
+
+func readRates(meter metricsMeter) [4]float64 {
+	return [4]float64{meter.Rate1(), meter.Rate5(), meter.Rate15(), meter.RateMean()}
+}
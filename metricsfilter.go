@@ -0,0 +1,224 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Selects metrics and their fields using the query parameters of a request.
+type metricsFilter struct {
+	prefixes []string
+	patterns []string
+	types    map[string]bool
+	fields   map[string]bool
+}
+
+// Maps short aliases to the field names used by go-metrics.
+var metricFieldAliases = map[string]string{
+	"p50":  "median",
+	"p75":  "75%",
+	"p95":  "95%",
+	"p99":  "99%",
+	"p999": "99.9%",
+}
+
+var metricFieldNames = []string{
+	"count", "value", "min", "max", "mean", "stddev",
+	"median", "75%", "95%", "99%", "99.9%",
+	"1m.rate", "5m.rate", "15m.rate", "mean.rate",
+}
+
+// Parses the query parameters 'prefix' and 'name' to select metrics by name,
+// 'type' to select metrics by type and 'fields' to select the fields of each
+// metric. All parameters can be repeated or contain comma separated values.
+// Names are matched as globs using the syntax of path.Match.
+func parseMetricsFilter(query url.Values) (metricsFilter, error) {
+	filter := metricsFilter{
+		prefixes: splitQueryValues(query["prefix"]),
+		patterns: splitQueryValues(query["name"]),
+	}
+
+	for _, pattern := range filter.patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter, fmt.Errorf("invalid name pattern %q", pattern)
+		}
+	}
+
+	for _, kind := range splitQueryValues(query["type"]) {
+		switch kind {
+		case counterMetric, gaugeMetric, meterMetric, histogramMetric, timerMetric:
+			if filter.types == nil {
+				filter.types = map[string]bool{}
+			}
+
+			filter.types[kind] = true
+
+		default:
+			return filter, fmt.Errorf("unknown metric type %q", kind)
+		}
+	}
+
+	for _, field := range splitQueryValues(query["fields"]) {
+		if alias, ok := metricFieldAliases[field]; ok {
+			field = alias
+		}
+
+		if !containsString(metricFieldNames, field) {
+			return filter, fmt.Errorf("unknown field %q, expected one of %s",
+				field, strings.Join(metricFieldNames, ", "))
+		}
+
+		if filter.fields == nil {
+			filter.fields = map[string]bool{}
+		}
+
+		filter.fields[field] = true
+	}
+
+	return filter, nil
+}
+
+// Returns true, if the request contains any of the filter parameters.
+func hasMetricsFilter(query url.Values) bool {
+	for _, param := range []string{"prefix", "name", "type", "fields", "pretty"} {
+		if _, ok := query[param]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter metricsFilter) matches(name string, snapshot metricSnapshot) bool {
+	if filter.types != nil && !filter.types[snapshot.kind] {
+		return false
+	}
+
+	if len(filter.prefixes) == 0 && len(filter.patterns) == 0 {
+		return true
+	}
+
+	for _, prefix := range filter.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	for _, pattern := range filter.patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the fields of the metric, using the same names as the
+// json representation of go-metrics.
+func (snapshot metricSnapshot) fields() map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	switch snapshot.kind {
+	case counterMetric:
+		fields["count"] = snapshot.count
+
+	case gaugeMetric:
+		fields["value"] = snapshot.value
+
+	case meterMetric:
+		fields["count"] = snapshot.count
+
+	case histogramMetric, timerMetric:
+		fields["count"] = snapshot.count
+		fields["min"] = snapshot.min
+		fields["max"] = snapshot.max
+		fields["mean"] = snapshot.mean
+		fields["stddev"] = snapshot.stddev
+
+		for idx, name := range []string{"median", "75%", "95%", "99%", "99.9%"} {
+			fields[name] = snapshot.percentiles[idx]
+		}
+	}
+
+	if snapshot.kind == meterMetric || snapshot.kind == timerMetric {
+		for idx, name := range []string{"1m.rate", "5m.rate", "15m.rate", "mean.rate"} {
+			fields[name] = snapshot.rates[idx]
+		}
+	}
+
+	return fields
+}
+
+// Writes the selected metrics and fields of the registry as json.
+func writeFilteredMetrics(w http.ResponseWriter, registry MetricsRegistry, filter metricsFilter, pretty bool) {
+	result := map[string]map[string]interface{}{}
+
+	iterable := eachMetric(registry, func(name string, metric interface{}) {
+		snapshot, ok := readMetric(metric)
+		if !ok || !filter.matches(name, snapshot) {
+			return
+		}
+
+		fields := snapshot.fields()
+		if filter.fields != nil {
+			for field := range fields {
+				if !filter.fields[field] {
+					delete(fields, field)
+				}
+			}
+
+			// skip metrics that have none of the requested fields
+			if len(fields) == 0 {
+				return
+			}
+		}
+
+		result[name] = fields
+	})
+
+	if !iterable {
+		http.Error(w, "The MetricsRegistry does not support iteration", http.StatusInternalServerError)
+		return
+	}
+
+	if !pretty {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	body, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
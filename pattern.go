@@ -0,0 +1,288 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	literalSegment segmentKind = iota
+	paramSegment
+	restSegment
+)
+
+type segment struct {
+	kind segmentKind
+
+	// the literal text of the segment or the name of the parameter.
+	value string
+}
+
+// A routePattern is the parsed form of a routes path. Segments in the form
+// of {name} match exactly one path segment, a trailing {name...} segment
+// matches the remaining path, including an empty one.
+type routePattern []segment
+
+func parsePattern(path string, wildcard bool) (routePattern, error) {
+	var pattern routePattern
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for idx, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("invalid segment %q in path %s", part, path)
+			}
+
+			pattern = append(pattern, segment{kind: literalSegment, value: part})
+			continue
+		}
+
+		name := part[1 : len(part)-1]
+
+		kind := paramSegment
+		if strings.HasSuffix(name, "...") {
+			if idx != len(parts)-1 {
+				return nil, fmt.Errorf("segment %q must be the last one in path %s", part, path)
+			}
+
+			kind = restSegment
+			name = strings.TrimSuffix(name, "...")
+		}
+
+		if name == "" || strings.ContainsAny(name, "{}.") {
+			return nil, fmt.Errorf("invalid parameter name in segment %q of path %s", part, path)
+		}
+
+		for _, other := range pattern {
+			if other.kind != literalSegment && other.value == name {
+				return nil, fmt.Errorf("duplicate parameter %q in path %s", name, path)
+			}
+		}
+
+		pattern = append(pattern, segment{kind: kind, value: name})
+	}
+
+	// a wildcard route matches everything below its path.
+	if wildcard && !pattern.hasRest() {
+		pattern = append(pattern, segment{kind: restSegment})
+	}
+
+	return pattern, nil
+}
+
+func (pattern routePattern) hasRest() bool {
+	return len(pattern) > 0 && pattern[len(pattern)-1].kind == restSegment
+}
+
+// Returns true, if the pattern contains named parameters.
+func (pattern routePattern) hasParams() bool {
+	for _, seg := range pattern {
+		if seg.kind != literalSegment && seg.value != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches the given path against this pattern. If the path matches, the
+// values of all named parameters are returned.
+func (pattern routePattern) match(path string) (map[string]string, bool) {
+	var parts []string
+	if trimmed := strings.Trim(path, "/"); trimmed != "" {
+		parts = strings.Split(trimmed, "/")
+	}
+
+	var params map[string]string
+	setParam := func(name, value string) {
+		if name == "" {
+			return
+		}
+
+		if params == nil {
+			params = make(map[string]string)
+		}
+
+		params[name] = value
+	}
+
+	for idx, seg := range pattern {
+		if seg.kind == restSegment {
+			setParam(seg.value, strings.Join(parts[idx:], "/"))
+			return params, true
+		}
+
+		if idx >= len(parts) {
+			return nil, false
+		}
+
+		switch seg.kind {
+		case literalSegment:
+			if parts[idx] != seg.value {
+				return nil, false
+			}
+
+		case paramSegment:
+			setParam(seg.value, parts[idx])
+		}
+	}
+
+	if len(parts) != len(pattern) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+func withPathParams(req *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return req
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), pathParamsKey, params))
+}
+
+// Returns the values of all path parameters that were matched for the
+// current request. For a route registered as /caches/{name}/stats the
+// map contains the key "name".
+func PathParams(req *http.Request) map[string]string {
+	params, _ := req.Context().Value(pathParamsKey).(map[string]string)
+	return params
+}
+
+// Returns the value of the named path parameter or an empty
+// string, if the parameter does not exist.
+func PathParam(req *http.Request, name string) string {
+	return PathParams(req)[name]
+}
+
+const (
+	literalRank = iota
+	paramRank
+	endRank
+	restRank
+)
+
+func (pattern routePattern) rankAt(idx int) int {
+	if idx >= len(pattern) {
+		return endRank
+	}
+
+	switch pattern[idx].kind {
+	case literalSegment:
+		return literalRank
+	case paramSegment:
+		return paramRank
+	default:
+		return restRank
+	}
+}
+
+// Compares two patterns by their specificity. A negative result means, that
+// the first pattern is more specific than the second one. Literal segments
+// are more specific than parameters, which are more specific than a trailing
+// rest segment. If one pattern matches a strict subset of the paths matched
+// by the other one, it is always ordered first.
+func comparePatterns(a, b routePattern) int {
+	for idx := 0; ; idx++ {
+		rankA, rankB := a.rankAt(idx), b.rankAt(idx)
+		if rankA != rankB {
+			return rankA - rankB
+		}
+
+		switch rankA {
+		case endRank, restRank:
+			return 0
+
+		case literalRank:
+			if cmp := strings.Compare(a[idx].value, b[idx].value); cmp != 0 {
+				return cmp
+			}
+		}
+	}
+}
+
+// Returns true, if the pattern matches every path the other pattern matches.
+func (pattern routePattern) contains(other routePattern) bool {
+	for idx := 0; ; idx++ {
+		rank, otherRank := pattern.rankAt(idx), other.rankAt(idx)
+
+		switch {
+		case rank == restRank:
+			return true
+
+		case rank == endRank || otherRank == endRank || otherRank == restRank:
+			return rank == otherRank
+
+		case rank == literalRank:
+			if otherRank != literalRank || pattern[idx].value != other[idx].value {
+				return false
+			}
+		}
+	}
+}
+
+// Returns true, if there is at least one path that is matched by both patterns.
+func (pattern routePattern) overlaps(other routePattern) bool {
+	for idx := 0; ; idx++ {
+		rank, otherRank := pattern.rankAt(idx), other.rankAt(idx)
+
+		switch {
+		case rank == restRank || otherRank == restRank:
+			return true
+
+		case rank == endRank || otherRank == endRank:
+			return rank == otherRank
+
+		case rank == literalRank && otherRank == literalRank:
+			if pattern[idx].value != other[idx].value {
+				return false
+			}
+		}
+	}
+}
+
+// Builds an example path that is matched by both patterns. The patterns
+// must overlap.
+func (pattern routePattern) commonPath(other routePattern) string {
+	var parts []string
+	for idx := 0; ; idx++ {
+		rank, otherRank := pattern.rankAt(idx), other.rankAt(idx)
+		if rank == endRank || rank == restRank || otherRank == endRank || otherRank == restRank {
+			break
+		}
+
+		switch {
+		case rank == literalRank:
+			parts = append(parts, pattern[idx].value)
+		case otherRank == literalRank:
+			parts = append(parts, other[idx].value)
+		default:
+			parts = append(parts, "x")
+		}
+	}
+
+	// a rest segment might require some more segments of the other pattern.
+	remaining := pattern
+	if rank := pattern.rankAt(len(parts)); rank == endRank || rank == restRank {
+		remaining = other
+	}
+
+	for idx := len(parts); idx < len(remaining) && remaining[idx].kind != restSegment; idx++ {
+		if remaining[idx].kind == literalSegment {
+			parts = append(parts, remaining[idx].value)
+		} else {
+			parts = append(parts, "x")
+		}
+	}
+
+	return "/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		wildcard bool
+		path     string
+		ok       bool
+		params   map[string]string
+	}{
+		{"/", false, "/", true, nil},
+		{"/", false, "/a", false, nil},
+		{"/metrics", false, "/metrics", true, nil},
+		{"/metrics", false, "/metrics/", true, nil},
+		{"/metrics", false, "/metrics/health", false, nil},
+		{"/metrics", false, "/", false, nil},
+		{"/caches/{name}", false, "/caches/users", true, map[string]string{"name": "users"}},
+		{"/caches/{name}", false, "/caches", false, nil},
+		{"/caches/{name}", false, "/caches/users/stats", false, nil},
+		{"/caches/{name}/stats", false, "/caches/users/stats", true, map[string]string{"name": "users"}},
+		{"/caches/{name}/stats", false, "/caches/users/size", false, nil},
+		{"/diff/{base}/{target}", false, "/diff/a/b", true, map[string]string{"base": "a", "target": "b"}},
+		{"/files/{path...}", false, "/files", true, map[string]string{"path": ""}},
+		{"/files/{path...}", false, "/files/a", true, map[string]string{"path": "a"}},
+		{"/files/{path...}", false, "/files/a/b/c", true, map[string]string{"path": "a/b/c"}},
+		{"/files/{path...}", false, "/other/a", false, nil},
+		{"/static", true, "/static", true, nil},
+		{"/static", true, "/static/css/main.css", true, nil},
+		{"/static", true, "/statics", false, nil},
+		{"/files/{path...}", true, "/files/a/b", true, map[string]string{"path": "a/b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			pattern, err := parsePattern(test.pattern, test.wildcard)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params, ok := pattern.match(test.path)
+			if ok != test.ok {
+				t.Fatalf("expected match=%v, got %v", test.ok, ok)
+			}
+
+			if !reflect.DeepEqual(params, test.params) {
+				t.Fatalf("expected params %v, got %v", test.params, params)
+			}
+		})
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	paths := []string{
+		"/a{b}",
+		"/{a}b",
+		"/{}",
+		"/{a.b}",
+		"/{rest...}/a",
+		"/{a}/{a}",
+		"/{a}/{a...}",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			if _, err := parsePattern(path, false); err == nil {
+				t.Fatalf("expected an error for %s", path)
+			}
+		})
+	}
+}
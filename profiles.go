@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	pprofH "net/http/pprof"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+)
+
+var profileDescriptions = map[string]string{
+	"allocs":       "A sampling of all past memory allocations.",
+	"block":        "Stack traces that led to blocking on synchronization primitives. Requires a block profile rate.",
+	"goroutine":    "Stack traces of all current goroutines.",
+	"heap":         "A sampling of memory allocations of live objects.",
+	"mutex":        "Stack traces of holders of contended mutexes. Requires a mutex profile fraction.",
+	"threadcreate": "Stack traces that led to the creation of new OS threads.",
+}
+
+// Serves any profile of the runtime/pprof package by name, including custom
+// profiles created with pprof.NewProfile. With 'gc=1' the garbage collector
+// runs before the profile is taken.
+func serveNamedProfile(w http.ResponseWriter, req *http.Request) {
+	name := PathParam(req, "name")
+	if pprof.Lookup(name) == nil {
+		http.Error(w, fmt.Sprintf("Unknown profile %q", name), http.StatusNotFound)
+		return
+	}
+
+	// the heap profile handles the parameter itself.
+	if gc, _ := strconv.Atoi(req.FormValue("gc")); gc > 0 && name != "heap" {
+		runtime.GC()
+	}
+
+	pprofH.Handler(name).ServeHTTP(w, req)
+}
+
+// Lists all currently known profiles with their descriptions.
+func namedProfileLinks() []link {
+	var links []link
+	for _, profile := range pprof.Profiles() {
+		description, ok := profileDescriptions[profile.Name()]
+		if !ok {
+			description = "A custom profile."
+		}
+
+		links = append(links, link{
+			Name:        pathOf("pprof", profile.Name()),
+			Description: fmt.Sprintf("%s Currently %d entries.", description, profile.Count()),
+		})
+	}
+
+	sort.Slice(links, func(i, j int) bool { return links[i].Name < links[j].Name })
+
+	return links
+}
+
+// Exposes all profiles of the runtime/pprof package at pprof/{name}. The
+// profiles accept the url parameters 'debug', 'gc' and 'seconds'. Profiles
+// created later on using pprof.NewProfile are available as well.
+//
+// The routes are part of WithPProfHandlers, use this only without it.
+func WithNamedProfiles() RouteConfig {
+	rc := Describe(
+		"A profile of the runtime/pprof package. Accepts the url parameters 'debug', 'gc' and 'seconds'.",
+		WithGetHandlerFunc("pprof/{name}", serveNamedProfile)).
+		Limited(Limit{Concurrency: 1})
+
+	rc.indexLinks = namedProfileLinks
+	return rc
+}
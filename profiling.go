@@ -0,0 +1,262 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// Configures the continuous profiling, see WithContinuousProfiling.
+type ProfilingOptions struct {
+	// Time between two captures, defaults to one minute.
+	Interval time.Duration
+
+	// Duration of the cpu profile of each capture, defaults to ten seconds.
+	CPUDuration time.Duration
+
+	// Fraction of the time the cpu profiler may run, defaults to 0.1. The cpu
+	// duration is shortened, if it exceeds this fraction of the interval.
+	MaxOverhead float64
+
+	// Names of the profiles to capture. Besides the profiles of the
+	// runtime/pprof package, 'cpu' is supported. Defaults to cpu, heap
+	// and goroutine.
+	Profiles []string
+
+	// Number of captures to keep, defaults to 60.
+	Capacity int
+
+	// Stores the profiles in this directory instead of keeping them in memory.
+	Directory string
+}
+
+// A stored profile of a capture.
+type capturedProfile struct {
+	Size int
+
+	data []byte
+	file string
+}
+
+// The profiles captured at one point in time.
+type profileCapture struct {
+	ID       string
+	Time     time.Time
+	Profiles map[string]*capturedProfile
+}
+
+// Captures profiles in the background and keeps the most recent ones,
+// see WithContinuousProfiling.
+type ProfileCollector struct {
+	options ProfilingOptions
+	loop    *backgroundLoop
+
+	lock     sync.Mutex
+	captures *ring[*profileCapture]
+}
+
+// Creates a collector that captures profiles as configured by the options.
+// Capturing starts once an admin handler using the collector was created and
+// continues until Stop is called.
+func NewProfileCollector(options ProfilingOptions) *ProfileCollector {
+	if options.Interval <= 0 {
+		options.Interval = time.Minute
+	}
+
+	if options.CPUDuration <= 0 {
+		options.CPUDuration = 10 * time.Second
+	}
+
+	if options.MaxOverhead <= 0 || options.MaxOverhead > 1 {
+		options.MaxOverhead = 0.1
+	}
+
+	if maxDuration := time.Duration(float64(options.Interval) * options.MaxOverhead); options.CPUDuration > maxDuration {
+		options.CPUDuration = maxDuration
+	}
+
+	if len(options.Profiles) == 0 {
+		options.Profiles = []string{"cpu", "heap", "goroutine"}
+	}
+
+	if options.Capacity < 1 {
+		options.Capacity = 60
+	}
+
+	collector := &ProfileCollector{options: options, captures: newRing[*profileCapture](options.Capacity)}
+	collector.loop = newBackgroundLoop(options.Interval, func() { collector.store(collector.capture()) })
+	return collector
+}
+
+// Stops capturing, a running cpu profile is stopped early.
+// The captured profiles are still served.
+func (collector *ProfileCollector) Stop() {
+	collector.loop.stop()
+}
+
+// Captures all configured profiles. Profiles that fail are logged and skipped.
+func (collector *ProfileCollector) capture() *profileCapture {
+	now := time.Now()
+
+	capture := &profileCapture{
+		ID:       now.UTC().Format("20060102T150405.000Z"),
+		Time:     now,
+		Profiles: map[string]*capturedProfile{},
+	}
+
+	for _, name := range collector.options.Profiles {
+		var buffer bytes.Buffer
+
+		var err error
+		if name == "cpu" {
+			err = collector.captureCPUProfile(&buffer)
+		} else if profile := pprof.Lookup(name); profile != nil {
+			err = profile.WriteTo(&buffer, 0)
+		} else {
+			err = fmt.Errorf("unknown profile")
+		}
+
+		var profile *capturedProfile
+		if err == nil {
+			profile, err = collector.keep(capture.ID, name, buffer.Bytes())
+		}
+
+		if err != nil {
+			log.Printf("Could not capture %s profile: %s", name, err)
+			continue
+		}
+
+		capture.Profiles[name] = profile
+	}
+
+	return capture
+}
+
+func (collector *ProfileCollector) captureCPUProfile(buffer *bytes.Buffer) error {
+	// fails if someone else is running a cpu profile right now.
+	if err := pprof.StartCPUProfile(buffer); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(collector.options.CPUDuration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-collector.loop.done():
+	}
+
+	pprof.StopCPUProfile()
+
+	return nil
+}
+
+// Keeps the profile in memory or writes it to the configured directory.
+func (collector *ProfileCollector) keep(id, name string, data []byte) (*capturedProfile, error) {
+	profile := &capturedProfile{Size: len(data)}
+
+	if collector.options.Directory == "" {
+		profile.data = data
+		return profile, nil
+	}
+
+	profile.file = filepath.Join(collector.options.Directory, fmt.Sprintf("%s-%s.pb.gz", name, id))
+	if err := os.WriteFile(profile.file, data, 0600); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// Adds a capture and evicts the oldest ones.
+func (collector *ProfileCollector) store(capture *profileCapture) {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	evicted, ok := collector.captures.add(capture)
+	if !ok {
+		return
+	}
+
+	for _, profile := range evicted.Profiles {
+		if profile.file != "" {
+			os.Remove(profile.file)
+		}
+	}
+}
+
+// Returns all captures, newest first.
+func (collector *ProfileCollector) list() []*profileCapture {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	return collector.captures.newestFirst()
+}
+
+func (collector *ProfileCollector) lookup(id, name string) *capturedProfile {
+	collector.lock.Lock()
+	defer collector.lock.Unlock()
+
+	for _, capture := range collector.captures.oldestFirst() {
+		if capture.ID == id {
+			return capture.Profiles[name]
+		}
+	}
+
+	return nil
+}
+
+func (collector *ProfileCollector) download(w http.ResponseWriter, req *http.Request) {
+	id, name := PathParam(req, "id"), PathParam(req, "profile")
+
+	profile := collector.lookup(id, name)
+	if profile == nil {
+		http.Error(w, fmt.Sprintf("No %s profile captured at %s", name, id), http.StatusNotFound)
+		return
+	}
+
+	data := profile.data
+	if profile.file != "" {
+		var err error
+		if data, err = os.ReadFile(profile.file); err != nil {
+			// the capture might have been evicted in the meantime.
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.pb.gz", name, id))
+	w.Write(data)
+}
+
+// Serves the profiles the collector captures continuously in the background,
+// so they are available once an incident is noticed. The captures are listed
+// at /profiling and can be downloaded at /profiling/{id}/{profile} and opened
+// with 'go tool pprof'. Capturing starts once the admin handler is created.
+//
+// While the cpu profile of a capture is running, pprof/profile is not
+// available and vice versa.
+func WithContinuousProfiling(collector *ProfileCollector) RouteConfig {
+	rc := RouteConfig{children: []RouteConfig{
+		Describe(
+			"The profiles captured in the background, newest first.",
+			WithGenericValue("/profiling", collector.list)),
+
+		Describe(
+			"Downloads a profile captured in the background.",
+			WithGetHandlerFunc("/profiling/{id}/{profile}", collector.download)),
+	}}
+
+	rc.setup = func(admin *adminContext) {
+		collector.loop.start()
+	}
+
+	return rc
+}
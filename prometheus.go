@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	jsonFormat        = "json"
+	prometheusFormat  = "prometheus"
+	openMetricsFormat = "openmetrics"
+)
+
+var metricFormatContentTypes = map[string]string{
+	jsonFormat:        "application/json",
+	prometheusFormat:  "text/plain; version=0.0.4; charset=utf-8",
+	openMetricsFormat: "application/openmetrics-text; version=1.0.0; charset=utf-8",
+}
+
+// Picks the output format for the metrics. The format can be forced using
+// the 'format' query parameter, otherwise the Accept header is used.
+// Clients without a preference get json.
+func negotiateMetricsFormat(req *http.Request) string {
+	if format := req.URL.Query().Get("format"); format != "" {
+		return format
+	}
+
+	format, bestQuality := jsonFormat, 0.0
+	for _, accepted := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+
+		var candidate string
+		switch mediaType {
+		case "application/openmetrics-text":
+			candidate = openMetricsFormat
+		case "text/plain":
+			candidate = prometheusFormat
+		case "application/json", "*/*":
+			candidate = jsonFormat
+		default:
+			continue
+		}
+
+		if quality > bestQuality {
+			format, bestQuality = candidate, quality
+		}
+	}
+
+	return format
+}
+
+// Renders the metrics of the registry in the prometheus text format or, if
+// openMetrics is set, in the OpenMetrics text format. Counters and gauges
+// become gauges, as go-metrics counters can be decremented. Meters provide
+// a counter with their count and a gauge with their rates. Histograms and
+// timers become summaries, timers are converted to seconds. The sum of a
+// summary is estimated using the mean of the sampled values.
+func writePrometheusMetrics(buffer *bytes.Buffer, registry MetricsRegistry, filter metricsFilter, openMetrics bool) bool {
+	seen := map[string]bool{}
+
+	ok := eachMetric(registry, func(name string, metric interface{}) {
+		snapshot, ok := readMetric(metric)
+		if !ok || !filter.matches(name, snapshot) {
+			return
+		}
+
+		name = prometheusName(name)
+		if snapshot.kind == timerMetric {
+			name += "_seconds"
+		}
+
+		// names might collide after sanitizing them, skip the duplicates.
+		if seen[name] {
+			return
+		}
+
+		seen[name] = true
+
+		switch snapshot.kind {
+		case counterMetric, gaugeMetric:
+			fmt.Fprintf(buffer, "# TYPE %s gauge\n", name)
+			fmt.Fprintf(buffer, "%s %s\n", name, formatPrometheusValue(snapshot.value))
+
+		case meterMetric:
+			family := name
+			if !openMetrics {
+				family = name + "_total"
+			}
+
+			fmt.Fprintf(buffer, "# TYPE %s counter\n", family)
+			fmt.Fprintf(buffer, "%s_total %d\n", name, snapshot.count)
+
+			fmt.Fprintf(buffer, "# TYPE %s_rate gauge\n", name)
+			for idx, window := range []string{"1m", "5m", "15m", "mean"} {
+				fmt.Fprintf(buffer, "%s_rate{window=%q} %s\n", name, window, formatPrometheusValue(snapshot.rates[idx]))
+			}
+
+		case histogramMetric, timerMetric:
+			scale := 1.0
+			if snapshot.kind == timerMetric {
+				scale = 1 / float64(time.Second)
+			}
+
+			fmt.Fprintf(buffer, "# TYPE %s summary\n", name)
+			for idx, quantile := range metricPercentiles {
+				fmt.Fprintf(buffer, "%s{quantile=\"%s\"} %s\n", name,
+					formatPrometheusValue(quantile), formatPrometheusValue(snapshot.percentiles[idx]*scale))
+			}
+
+			// go-metrics only sums the values in its sample reservoir, while the
+			// count includes all updates. The sum is estimated from the mean instead.
+			sum := snapshot.mean * float64(snapshot.count)
+			fmt.Fprintf(buffer, "%s_sum %s\n", name, formatPrometheusValue(sum*scale))
+			fmt.Fprintf(buffer, "%s_count %d\n", name, snapshot.count)
+		}
+	})
+
+	if openMetrics {
+		buffer.WriteString("# EOF\n")
+	}
+
+	return ok
+}
+
+// Replaces all characters that are not allowed in prometheus metric names.
+func prometheusName(name string) string {
+	sanitized := []byte(name)
+	for idx, char := range sanitized {
+		valid := char == '_' || char == ':' ||
+			char >= 'a' && char <= 'z' ||
+			char >= 'A' && char <= 'Z' ||
+			char >= '0' && char <= '9' && idx > 0
+
+		if !valid {
+			sanitized[idx] = '_'
+		}
+	}
+
+	return string(sanitized)
+}
+
+func formatPrometheusValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Serves the registry as json or in the prometheus text format.
+// The metrics can be filtered, see parseMetricsFilter.
+func metricsHandler(registry MetricsRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+
+		filter, err := parseMetricsFilter(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		format := negotiateMetricsFormat(req)
+
+		switch format {
+		case jsonFormat:
+			if hasMetricsFilter(query) {
+				writeFilteredMetrics(w, registry, filter, query.Get("pretty") != "false")
+			} else {
+				writeJSON(w, http.StatusOK, registry)
+			}
+
+		case prometheusFormat, openMetricsFormat:
+			var buffer bytes.Buffer
+			if !writePrometheusMetrics(&buffer, registry, filter, format == openMetricsFormat) {
+				http.Error(w, "The MetricsRegistry does not support iteration", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", metricFormatContentTypes[format])
+			w.Write(buffer.Bytes())
+
+		default:
+			http.Error(w, "Unknown format "+format+", expected json, prometheus or openmetrics", http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A file that is parsed once and parsed again when it changes on disk.
+type reloadingFile struct {
+	path           string
+	reloadInterval time.Duration
+	parse          func(content []byte) (interface{}, error)
+
+	lock        sync.Mutex
+	value       interface{}
+	modTime     time.Time
+	lastChecked time.Time
+}
+
+// Loads the file. It is checked for modifications at most once per reload
+// interval. A reload interval of zero disables reloading.
+func newReloadingFile(path string, reloadInterval time.Duration, parse func([]byte) (interface{}, error)) (*reloadingFile, error) {
+	file := &reloadingFile{path: path, reloadInterval: reloadInterval, parse: parse}
+	if err := file.reload(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Returns the most recently parsed value. If a reload fails,
+// the previous value stays valid.
+func (file *reloadingFile) get() interface{} {
+	file.lock.Lock()
+	defer file.lock.Unlock()
+
+	if file.reloadInterval > 0 && time.Since(file.lastChecked) >= file.reloadInterval {
+		if err := file.reload(); err != nil {
+			log.Printf("Could not reload file %s: %s", file.path, err)
+		}
+	}
+
+	return file.value
+}
+
+func (file *reloadingFile) reload() error {
+	file.lastChecked = time.Now()
+
+	stat, err := os.Stat(file.path)
+	if err != nil {
+		return err
+	}
+
+	if file.value != nil && stat.ModTime().Equal(file.modTime) {
+		return nil
+	}
+
+	content, err := os.ReadFile(file.path)
+	if err != nil {
+		return err
+	}
+
+	value, err := file.parse(content)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file.path, err)
+	}
+
+	file.value = value
+	file.modTime = stat.ModTime()
+	return nil
+}
+
+// Calls the callback for each line of the file that is neither
+// empty nor a comment. Lines are split at the first colon.
+func parseColonSeparated(content []byte, callback func(key, value string) error) error {
+	for idx, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sep := strings.IndexByte(line, ':')
+		if sep <= 0 {
+			return fmt.Errorf("line %d: expected 'name:value'", idx+1)
+		}
+
+		if err := callback(line[:sep], line[sep+1:]); err != nil {
+			return fmt.Errorf("line %d: %s", idx+1, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package admin
+
+// A buffer of fixed size that keeps the most recently added values.
+// It is not safe for concurrent use.
+type ring[T any] struct {
+	values []T
+	next   int
+	full   bool
+}
+
+func newRing[T any](capacity int) *ring[T] {
+	return &ring[T]{values: make([]T, capacity)}
+}
+
+// Adds a value. If the buffer is full, the oldest value is
+// replaced and returned.
+func (buffer *ring[T]) add(value T) (evicted T, ok bool) {
+	evicted, ok = buffer.values[buffer.next], buffer.full
+
+	buffer.values[buffer.next] = value
+	buffer.next = (buffer.next + 1) % len(buffer.values)
+	buffer.full = buffer.full || buffer.next == 0
+
+	return evicted, ok
+}
+
+// Returns the values, oldest first.
+func (buffer *ring[T]) oldestFirst() []T {
+	if !buffer.full {
+		return append([]T{}, buffer.values[:buffer.next]...)
+	}
+
+	result := append([]T{}, buffer.values[buffer.next:]...)
+	return append(result, buffer.values[:buffer.next]...)
+}
+
+// Returns the values, newest first.
+func (buffer *ring[T]) newestFirst() []T {
+	result := buffer.oldestFirst()
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+
+	return result
+}
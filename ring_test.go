@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	buffer := newRing[int](3)
+
+	if values := buffer.oldestFirst(); len(values) != 0 {
+		t.Fatalf("expected an empty ring, got %v", values)
+	}
+
+	for value := 1; value <= 3; value++ {
+		if _, ok := buffer.add(value); ok {
+			t.Fatalf("expected no eviction when adding %d", value)
+		}
+	}
+
+	if evicted, ok := buffer.add(4); !ok || evicted != 1 {
+		t.Fatalf("expected 1 to be evicted, got %d (%v)", evicted, ok)
+	}
+
+	if values := buffer.oldestFirst(); !reflect.DeepEqual(values, []int{2, 3, 4}) {
+		t.Fatalf("unexpected values, oldest first: %v", values)
+	}
+
+	if values := buffer.newestFirst(); !reflect.DeepEqual(values, []int{4, 3, 2}) {
+		t.Fatalf("unexpected values, newest first: %v", values)
+	}
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"net/http"
+)
+
+// Requires the caller to have all of the given roles to call the route
+// or any of its children. Roles of nested configs add up. The routes must be
+// protected by an authenticator, e.g. using RequireAuthWith, and the
+// authenticator must grant roles to the callers identity, see MapRoles.
+//
+// Callers lacking a role are rejected with 403 and the
+// route is not shown to them on the index page.
+func (rc RouteConfig) RequireRoles(roles ...string) RouteConfig {
+	rc.roles = append(append([]string{}, rc.roles...), roles...)
+	return rc
+}
+
+// Requires the given role for all route configs and their children.
+func RequireRole(role string, configs ...RouteConfig) RouteConfig {
+	return Group(configs...).RequireRoles(role)
+}
+
+type roleMapping struct {
+	auth  Authenticator
+	roles map[string][]string
+}
+
+// Wraps an authenticator and grants roles to the identities it
+// authenticates. The map contains the roles for each identity name.
+func MapRoles(auth Authenticator, roles map[string][]string) Authenticator {
+	return roleMapping{auth: auth, roles: roles}
+}
+
+func (mapping roleMapping) Authenticate(req *http.Request) (Identity, bool) {
+	identity, ok := mapping.auth.Authenticate(req)
+	if ok {
+		identity.Roles = append(identity.Roles[:len(identity.Roles):len(identity.Roles)], mapping.roles[identity.Name]...)
+	}
+
+	return identity, ok
+}
+
+func (mapping roleMapping) Challenge() string {
+	if challenger, ok := mapping.auth.(challenger); ok {
+		return challenger.Challenge()
+	}
+
+	return ""
+}
+
+func requireRolesHandler(roles []string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		identity, _ := IdentityFromRequest(req)
+		if !identity.HasRoles(roles...) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// Authenticates a request against the guards of the routes,
+// each guard is only asked once.
+type identityCache struct {
+	req     *http.Request
+	results map[*authGuard]*Identity
+}
+
+func newIdentityCache(req *http.Request) *identityCache {
+	return &identityCache{req: req, results: map[*authGuard]*Identity{}}
+}
+
+func (cache *identityCache) identify(guard *authGuard) (Identity, bool) {
+	result, seen := cache.results[guard]
+	if !seen {
+		if identity, ok := guard.auth.Authenticate(cache.req); ok {
+			result = &identity
+		}
+
+		cache.results[guard] = result
+	}
+
+	if result == nil {
+		return Identity{}, false
+	}
+
+	return *result, true
+}
+
+// Checks if the request passes all guards of the route and if
+// the resulting identity has the given roles.
+func (cache *identityCache) hasRoles(route Route, roles []string) bool {
+	identity, ok := cache.passesGuards(route)
+	return ok && len(route.guards) > 0 && identity.HasRoles(roles...)
+}
+
+// Checks if the request passes all guards of the route and returns the
+// identity of the innermost guard. Routes without guards are always passed.
+func (cache *identityCache) passesGuards(route Route) (Identity, bool) {
+	var identity Identity
+	for _, guard := range route.guards {
+		var ok bool
+		if identity, ok = cache.identify(guard); !ok {
+			return Identity{}, false
+		}
+	}
+
+	return identity, true
+}
+
+// Checks if any of the guards of the routes accepts the request.
+func (cache *identityCache) authenticatedByAny(routes []Route) bool {
+	for _, route := range routes {
+		for _, guard := range route.guards {
+			if _, ok := cache.identify(guard); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Responds with 401 and the challenges of all guards of the given routes.
+func challengeAll(w http.ResponseWriter, routes []Route) {
+	seen := map[*authGuard]bool{}
+	for _, route := range routes {
+		for _, guard := range route.guards {
+			if !seen[guard] {
+				seen[guard] = true
+				guard.challenge(w)
+			}
+		}
+	}
+
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,317 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A sample of the most important runtime statistics.
+type RuntimeSample struct {
+	Time time.Time
+
+	// Bytes occupied by live and not yet swept objects on the heap.
+	HeapBytes uint64
+
+	// The heap size the garbage collector is aiming for.
+	HeapGoalBytes uint64
+
+	Goroutines uint64
+
+	// Number of completed gc cycles and the time the application was paused
+	// by the gc since the previous sample. The first sample covers the time
+	// since the process was started.
+	GCCycles uint64
+	GCPause  time.Duration
+
+	// Fraction of the available cpu time (as defined by GOMAXPROCS) used
+	// by the process since the previous sample, as estimated by the runtime.
+	CPUUsage float64
+}
+
+var runtimeSampleMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/gc/heap/goal:bytes",
+	"/sched/goroutines:goroutines",
+	"/sched/gomaxprocs:threads",
+	"/gc/cycles/total:gc-cycles",
+	"/cpu/classes/gc/pause:cpu-seconds",
+	"/cpu/classes/idle:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+}
+
+// Records runtime samples into a bounded ring buffer, see WithRuntimeHistory.
+type RuntimeSampler struct {
+	loop *backgroundLoop
+
+	lock    sync.Mutex
+	samples *ring[RuntimeSample]
+
+	// cumulative values of the previous sample
+	gcCycles, pauseSeconds, idleSeconds, totalSeconds float64
+}
+
+// Creates a sampler that records a sample once per interval and keeps the
+// given number of samples. Sampling starts once an admin handler using the
+// sampler was created and continues until Stop is called.
+func NewRuntimeSampler(interval time.Duration, capacity int) *RuntimeSampler {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	if capacity < 1 {
+		capacity = 360
+	}
+
+	sampler := &RuntimeSampler{samples: newRing[RuntimeSample](capacity)}
+	sampler.loop = newBackgroundLoop(interval, sampler.sample)
+	return sampler
+}
+
+// Stops sampling. The recorded samples are still served.
+func (sampler *RuntimeSampler) Stop() {
+	sampler.loop.stop()
+}
+
+func (sampler *RuntimeSampler) sample() {
+	samples := make([]metrics.Sample, len(runtimeSampleMetrics))
+	for idx, name := range runtimeSampleMetrics {
+		samples[idx].Name = name
+	}
+
+	metrics.Read(samples)
+
+	values := make([]float64, len(samples))
+	for idx, sample := range samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			values[idx] = float64(sample.Value.Uint64())
+		case metrics.KindFloat64:
+			values[idx] = sample.Value.Float64()
+		}
+	}
+
+	heap, goal, goroutines, maxProcs := values[0], values[1], values[2], values[3]
+	gcCycles, pauseSeconds, idleSeconds, totalSeconds := values[4], values[5], values[6], values[7]
+
+	sampler.lock.Lock()
+	defer sampler.lock.Unlock()
+
+	sample := RuntimeSample{
+		Time:          time.Now(),
+		HeapBytes:     uint64(heap),
+		HeapGoalBytes: uint64(goal),
+		Goroutines:    uint64(goroutines),
+		GCCycles:      uint64(gcCycles - sampler.gcCycles),
+	}
+
+	// the pause is accounted for every processor
+	if maxProcs > 0 {
+		pause := (pauseSeconds - sampler.pauseSeconds) / maxProcs
+		sample.GCPause = time.Duration(pause * float64(time.Second))
+	}
+
+	if total := totalSeconds - sampler.totalSeconds; total > 0 {
+		sample.CPUUsage = 1 - (idleSeconds-sampler.idleSeconds)/total
+	}
+
+	sampler.gcCycles, sampler.pauseSeconds = gcCycles, pauseSeconds
+	sampler.idleSeconds, sampler.totalSeconds = idleSeconds, totalSeconds
+
+	sampler.samples.add(sample)
+}
+
+// Returns the recorded samples, oldest first.
+func (sampler *RuntimeSampler) history() []RuntimeSample {
+	sampler.lock.Lock()
+	defer sampler.lock.Unlock()
+
+	return sampler.samples.oldestFirst()
+}
+
+// A single chart on the history page.
+type sparkline struct {
+	Title    string
+	Points   string
+	Current  string
+	Min, Max string
+}
+
+const sparklineWidth, sparklineHeight = 600.0, 60.0
+
+func newSparkline(title string, samples []RuntimeSample, value func(RuntimeSample) float64, format func(float64) string) sparkline {
+	line := sparkline{Title: title}
+	if len(samples) == 0 {
+		return line
+	}
+
+	min, max := value(samples[0]), value(samples[0])
+	for _, sample := range samples {
+		if v := value(sample); v < min {
+			min = v
+		} else if v > max {
+			max = v
+		}
+	}
+
+	points := make([]string, len(samples))
+	for idx, sample := range samples {
+		x := sparklineWidth
+		if len(samples) > 1 {
+			x = float64(idx) * sparklineWidth / float64(len(samples)-1)
+		}
+
+		// keep constant values in the middle of the chart
+		y := sparklineHeight / 2
+		if max > min {
+			y = sparklineHeight - (value(sample)-min)/(max-min)*sparklineHeight
+		}
+
+		points[idx] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	line.Points = strings.Join(points, " ")
+	line.Current = format(value(samples[len(samples)-1]))
+	line.Min, line.Max = format(min), format(max)
+	return line
+}
+
+func formatBytes(value float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	idx := 0
+	for ; value >= 1024 && idx < len(units)-1; idx++ {
+		value /= 1024
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[idx])
+}
+
+type historyContext struct {
+	Interval time.Duration
+	Samples  int
+	Charts   []sparkline
+}
+
+const historyTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<title>Runtime history</title>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta http-equiv="refresh" content="{{ .Interval.Seconds }}">
+	<style>
+		body {
+			font-family: sans-serif;
+			margin: 2em;
+		}
+
+		svg {
+			width: 600px;
+			height: 60px;
+			background: #f8f8f8;
+		}
+
+		polyline {
+			fill: none;
+			stroke: #337ab7;
+			stroke-width: 1.5;
+		}
+
+		.values {
+			color: #777;
+			font-size: small;
+		}
+	</style>
+</head>
+<body>
+	<h1>Runtime history</h1>
+	<p class="values">{{ .Samples }} samples, one every {{ .Interval }}</p>
+	{{ range .Charts }}
+		<h3>{{ .Title }}</h3>
+		<svg viewBox="-2 -2 604 64" preserveAspectRatio="none"><polyline points="{{ .Points }}"/></svg>
+		<div class="values">current {{ .Current }}, min {{ .Min }}, max {{ .Max }}</div>
+	{{ end }}
+</body>
+</html>`
+
+// Serves the samples recorded by the sampler as json at /runtime/history
+// and with charts at /runtime/history/charts. The sampler records the heap
+// size, goroutine count, gc pauses and cpu usage and starts once the admin
+// handler is created.
+func WithRuntimeHistory(sampler *RuntimeSampler) RouteConfig {
+	tmpl, err := template.New("runtimeHistory").Parse(historyTemplate)
+	if err != nil {
+		// should never happen!
+		panic(err)
+	}
+
+	rc := RouteConfig{children: []RouteConfig{
+		Describe(
+			"Recent samples of heap size, goroutines, gc pauses and cpu usage, oldest first.",
+			WithGenericValue("/runtime/history", sampler.history)),
+
+		Describe(
+			"Charts of the recent heap size, goroutines, gc pauses and cpu usage.",
+			WithGetHandlerFunc("/runtime/history/charts", func(w http.ResponseWriter, req *http.Request) {
+				samples := sampler.history()
+
+				formatCount := func(value float64) string { return fmt.Sprintf("%.0f", value) }
+
+				templateContext := historyContext{
+					Interval: sampler.loop.interval,
+					Samples:  len(samples),
+					Charts: []sparkline{
+						newSparkline("Heap", samples, func(sample RuntimeSample) float64 {
+							return float64(sample.HeapBytes)
+						}, formatBytes),
+
+						newSparkline("Heap goal", samples, func(sample RuntimeSample) float64 {
+							return float64(sample.HeapGoalBytes)
+						}, formatBytes),
+
+						newSparkline("Goroutines", samples, func(sample RuntimeSample) float64 {
+							return float64(sample.Goroutines)
+						}, formatCount),
+
+						newSparkline("GC cycles", samples, func(sample RuntimeSample) float64 {
+							return float64(sample.GCCycles)
+						}, formatCount),
+
+						newSparkline("GC pause", samples, func(sample RuntimeSample) float64 {
+							return float64(sample.GCPause)
+						}, func(value float64) string {
+							return time.Duration(value).String()
+						}),
+
+						newSparkline("CPU usage", samples, func(sample RuntimeSample) float64 {
+							return sample.CPUUsage
+						}, func(value float64) string {
+							return fmt.Sprintf("%.1f%%", value*100)
+						}),
+					},
+				}
+
+				buffer := &bytes.Buffer{}
+				if err := tmpl.Execute(buffer, templateContext); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/html")
+				w.Write(buffer.Bytes())
+			})),
+	}}
+
+	rc.setup = func(admin *adminContext) {
+		sampler.loop.start()
+	}
+
+	return rc
+}
@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A single sample of the runtime/metrics package.
+type runtimeMetric struct {
+	Name        string
+	Description string
+	Unit        string
+	Cumulative  bool
+
+	Value     interface{}       `json:",omitempty"`
+	Histogram *runtimeHistogram `json:",omitempty"`
+}
+
+// A histogram in a readable form. Empty buckets are omitted, bounds
+// are formatted using the unit of the metric.
+type runtimeHistogram struct {
+	Count     uint64
+	Quantiles map[string]string `json:",omitempty"`
+	Buckets   []runtimeHistogramBucket
+}
+
+type runtimeHistogramBucket struct {
+	From  string
+	To    string
+	Count uint64
+}
+
+var runtimeHistogramQuantiles = []struct {
+	name     string
+	quantile float64
+}{
+	{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99}, {"max", 1},
+}
+
+// Reads all samples of the runtime/metrics package that match
+// one of the given names. A name ending with a slash selects all
+// metrics with that prefix. Without names, all samples are read.
+func readRuntimeMetrics(names []string) []runtimeMetric {
+	var descriptions []metrics.Description
+	for _, description := range metrics.All() {
+		if description.Kind != metrics.KindBad && matchesRuntimeMetric(names, description.Name) {
+			descriptions = append(descriptions, description)
+		}
+	}
+
+	samples := make([]metrics.Sample, len(descriptions))
+	for idx, description := range descriptions {
+		samples[idx].Name = description.Name
+	}
+
+	metrics.Read(samples)
+
+	result := make([]runtimeMetric, 0, len(samples))
+	for idx, sample := range samples {
+		description := descriptions[idx]
+
+		metric := runtimeMetric{
+			Name:        description.Name,
+			Description: description.Description,
+			Unit:        description.Name[strings.LastIndexByte(description.Name, ':')+1:],
+			Cumulative:  description.Cumulative,
+		}
+
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			metric.Value = sample.Value.Uint64()
+
+		case metrics.KindFloat64:
+			metric.Value = sample.Value.Float64()
+
+		case metrics.KindFloat64Histogram:
+			metric.Histogram = readRuntimeHistogram(sample.Value.Float64Histogram(), metric.Unit)
+
+		default:
+			continue
+		}
+
+		result = append(result, metric)
+	}
+
+	return result
+}
+
+func matchesRuntimeMetric(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	for _, candidate := range names {
+		if candidate == name || strings.HasSuffix(candidate, "/") && strings.HasPrefix(name, candidate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readRuntimeHistogram(histogram *metrics.Float64Histogram, unit string) *runtimeHistogram {
+	result := &runtimeHistogram{Buckets: []runtimeHistogramBucket{}}
+
+	for idx, count := range histogram.Counts {
+		result.Count += count
+
+		if count > 0 {
+			result.Buckets = append(result.Buckets, runtimeHistogramBucket{
+				From:  formatRuntimeValue(histogram.Buckets[idx], unit),
+				To:    formatRuntimeValue(histogram.Buckets[idx+1], unit),
+				Count: count,
+			})
+		}
+	}
+
+	if result.Count == 0 {
+		return result
+	}
+
+	// estimate the quantiles using the upper bound of the bucket
+	result.Quantiles = map[string]string{}
+	for _, q := range runtimeHistogramQuantiles {
+		threshold := uint64(math.Ceil(q.quantile * float64(result.Count)))
+
+		var seen uint64
+		for idx, count := range histogram.Counts {
+			seen += count
+			if count > 0 && seen >= threshold {
+				result.Quantiles[q.name] = formatRuntimeValue(histogram.Buckets[idx+1], unit)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func formatRuntimeValue(value float64, unit string) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+
+	case math.IsInf(value, -1):
+		return "-Inf"
+
+	case unit == "seconds":
+		return time.Duration(value * float64(time.Second)).String()
+
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// Serves all samples of the runtime/metrics package, including their description
+// and unit. In contrast to runtime.ReadMemStats, reading them does not stop the world.
+// Samples can be selected using the 'name' query parameter, a name ending with a slash
+// selects all metrics below that prefix, e.g. '/sched/'.
+func WithRuntimeMetrics() RouteConfig {
+	return Describe(
+		"All metrics of the runtime/metrics package. Select metrics with the 'name' parameter.",
+		WithGetHandlerFunc("/runtime/metrics", func(w http.ResponseWriter, req *http.Request) {
+			names := splitQueryValues(req.URL.Query()["name"])
+			writeJSON(w, http.StatusOK, readRuntimeMetrics(names))
+		}))
+}
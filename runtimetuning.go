@@ -0,0 +1,366 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The current values of the tunable runtime settings.
+type runtimeSettings struct {
+	// The gc percent, -1 if the garbage collector is turned off.
+	GCPercent int64
+
+	// The soft memory limit in bytes, math.MaxInt64 if there is no limit.
+	MemoryLimit int64
+
+	GOMAXPROCS int64
+
+	// The runtime can not report the block profile rate,
+	// so it is only known after it was set using this route.
+	BlockProfileRate *int64 `json:",omitempty"`
+
+	MutexProfileFraction int64
+
+	// Settings that will be reverted, mapped to the time of the revert.
+	Reverts map[string]time.Time `json:",omitempty"`
+}
+
+// A runtime setting that can be changed using WithRuntimeTuning.
+type tunableSetting struct {
+	name  string
+	parse func(value string) (int64, error)
+	get   func(tuner *runtimeTuner) int64
+	set   func(tuner *runtimeTuner, value int64)
+}
+
+const (
+	// GOMAXPROCS may be set to at most this multiple of the number of cpus.
+	maxGOMAXPROCSFactor = 4
+
+	// A lower memory limit would let the gc run nearly all the time.
+	minMemoryLimit = 16 << 20
+)
+
+var tunableSettings = []tunableSetting{
+	{
+		name: "gc_percent",
+		parse: func(value string) (int64, error) {
+			if value == "off" {
+				return -1, nil
+			}
+
+			return parseIntInRange(value, -1, math.MaxInt32)
+		},
+		get: func(tuner *runtimeTuner) int64 {
+			return readGCPercent()
+		},
+		set: func(tuner *runtimeTuner, value int64) {
+			debug.SetGCPercent(int(value))
+		},
+	},
+	{
+		name:  "memory_limit",
+		parse: parseMemoryLimit,
+		get: func(tuner *runtimeTuner) int64 {
+			return debug.SetMemoryLimit(-1)
+		},
+		set: func(tuner *runtimeTuner, value int64) {
+			debug.SetMemoryLimit(value)
+		},
+	},
+	{
+		name: "gomaxprocs",
+		parse: func(value string) (int64, error) {
+			// the scheduler does not cope with huge values and stalls the process.
+			return parseIntInRange(value, 1, int64(maxGOMAXPROCSFactor*runtime.NumCPU()))
+		},
+		get: func(tuner *runtimeTuner) int64 {
+			return int64(runtime.GOMAXPROCS(0))
+		},
+		set: func(tuner *runtimeTuner, value int64) {
+			runtime.GOMAXPROCS(int(value))
+		},
+	},
+	{
+		name: "block_profile_rate",
+		parse: func(value string) (int64, error) {
+			return parseIntInRange(value, 0, math.MaxInt32)
+		},
+		get: func(tuner *runtimeTuner) int64 {
+			// the rate is off by default
+			if tuner.blockProfileRate == nil {
+				return 0
+			}
+
+			return *tuner.blockProfileRate
+		},
+		set: func(tuner *runtimeTuner, value int64) {
+			runtime.SetBlockProfileRate(int(value))
+			tuner.blockProfileRate = &value
+		},
+	},
+	{
+		name: "mutex_profile_fraction",
+		parse: func(value string) (int64, error) {
+			return parseIntInRange(value, 0, math.MaxInt32)
+		},
+		get: func(tuner *runtimeTuner) int64 {
+			return int64(runtime.SetMutexProfileFraction(-1))
+		},
+		set: func(tuner *runtimeTuner, value int64) {
+			runtime.SetMutexProfileFraction(int(value))
+		},
+	},
+}
+
+func readGCPercent() int64 {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	metrics.Read(samples)
+
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return 100
+	}
+
+	// a disabled gc is reported as the maximum value
+	value := samples[0].Value.Uint64()
+	if value > math.MaxInt32 {
+		return -1
+	}
+
+	return int64(value)
+}
+
+func parseIntInRange(value string, min, max int64) (int64, error) {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+
+	if parsed < min || parsed > max {
+		return 0, fmt.Errorf("%d is not within %d and %d", parsed, min, max)
+	}
+
+	return parsed, nil
+}
+
+// Parses a number of bytes with an optional unit like 'MiB' or 'GB'.
+// 'off' disables the memory limit.
+func parseMemoryLimit(value string) (int64, error) {
+	if value == "off" {
+		return math.MaxInt64, nil
+	}
+
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+
+	factor := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+
+	parsed, err := parseIntInRange(value, 0, math.MaxInt64/factor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit: %s", err)
+	}
+
+	if parsed*factor < minMemoryLimit {
+		return 0, fmt.Errorf("memory limit must be at least %s", formatBytes(minMemoryLimit))
+	}
+
+	return parsed * factor, nil
+}
+
+// Applies changes to the runtime settings and reverts them after a ttl.
+type runtimeTuner struct {
+	lock             sync.Mutex
+	blockProfileRate *int64
+
+	// Each change increments the version of a setting, so
+	// a revert does not undo a more recent change.
+	versions map[string]int
+	reverts  map[string]time.Time
+
+	// The values pending reverts restore. Consecutive temporary changes
+	// keep the value from before the first one.
+	baselines map[string]int64
+}
+
+func (tuner *runtimeTuner) settings() runtimeSettings {
+	tuner.lock.Lock()
+	defer tuner.lock.Unlock()
+
+	settings := runtimeSettings{
+		GCPercent:            readGCPercent(),
+		MemoryLimit:          debug.SetMemoryLimit(-1),
+		GOMAXPROCS:           int64(runtime.GOMAXPROCS(0)),
+		BlockProfileRate:     tuner.blockProfileRate,
+		MutexProfileFraction: int64(runtime.SetMutexProfileFraction(-1)),
+	}
+
+	if len(tuner.reverts) > 0 {
+		settings.Reverts = map[string]time.Time{}
+		for name, revertAt := range tuner.reverts {
+			settings.Reverts[name] = revertAt
+		}
+	}
+
+	return settings
+}
+
+func (tuner *runtimeTuner) handleUpdate(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if value := req.Form.Get("ttl"); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 {
+			http.Error(w, fmt.Sprintf("invalid ttl %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// validate all values before changing anything
+	changes := map[string]int64{}
+	for _, setting := range tunableSettings {
+		value := strings.TrimSpace(req.Form.Get(setting.name))
+		if value == "" {
+			continue
+		}
+
+		parsed, err := setting.parse(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid value for %s: %s", setting.name, err), http.StatusBadRequest)
+			return
+		}
+
+		changes[setting.name] = parsed
+	}
+
+	if len(changes) == 0 {
+		names := make([]string, len(tunableSettings))
+		for idx, setting := range tunableSettings {
+			names[idx] = setting.name
+		}
+
+		http.Error(w, "no setting given, expected any of "+strings.Join(names, ", "), http.StatusBadRequest)
+		return
+	}
+
+	tuner.apply(req, changes, ttl)
+
+	writeJSON(w, http.StatusOK, tuner.settings())
+}
+
+func (tuner *runtimeTuner) apply(req *http.Request, changes map[string]int64, ttl time.Duration) {
+	tuner.lock.Lock()
+	defer tuner.lock.Unlock()
+
+	for _, setting := range tunableSettings {
+		value, ok := changes[setting.name]
+		if !ok {
+			continue
+		}
+
+		previous := setting.get(tuner)
+		setting.set(tuner, value)
+
+		tuner.versions[setting.name]++
+
+		baseline, pending := tuner.baselines[setting.name]
+		if !pending {
+			baseline = previous
+		}
+
+		delete(tuner.reverts, setting.name)
+		delete(tuner.baselines, setting.name)
+
+		change := fmt.Sprintf("from %d to %d", previous, value)
+		if ttl > 0 {
+			change += fmt.Sprintf(" for %s", ttl)
+			tuner.scheduleRevert(setting, baseline, ttl)
+		}
+
+		AddAuditDetail(req, setting.name, change)
+		log.Printf("Changed runtime setting %s: %s", setting.name, change)
+	}
+}
+
+// Reverts the setting to the baseline value after the ttl,
+// if it was not changed again in the meantime.
+func (tuner *runtimeTuner) scheduleRevert(setting tunableSetting, baseline int64, ttl time.Duration) {
+	version := tuner.versions[setting.name]
+	tuner.reverts[setting.name] = time.Now().Add(ttl)
+	tuner.baselines[setting.name] = baseline
+
+	time.AfterFunc(ttl, func() {
+		tuner.lock.Lock()
+		defer tuner.lock.Unlock()
+
+		if tuner.versions[setting.name] != version {
+			return
+		}
+
+		setting.set(tuner, baseline)
+		delete(tuner.reverts, setting.name)
+		delete(tuner.baselines, setting.name)
+
+		log.Printf("Reverted runtime setting %s to %d", setting.name, baseline)
+	})
+}
+
+// Shows and changes the gc percent, the memory limit, GOMAXPROCS, the block profile
+// rate and the mutex profile fraction of the running process. Changes are applied
+// using PUT or POST with the parameters gc_percent, memory_limit, gomaxprocs,
+// block_profile_rate and mutex_profile_fraction. The memory limit accepts units
+// like '512MiB', 'off' disables the gc or the memory limit. With a 'ttl' like '10m',
+// the changes are reverted after the given duration. Another temporary change of
+// a setting replaces the ttl, but still reverts to the value from before the first
+// change.
+//
+// GOMAXPROCS is limited to four times the number of cpus and the memory limit
+// must be at least 16MiB.
+//
+// Each change is logged and added to the audit log, see WithAudit.
+func WithRuntimeTuning() RouteConfig {
+	tuner := &runtimeTuner{
+		versions:  map[string]int{},
+		reverts:   map[string]time.Time{},
+		baselines: map[string]int64{},
+	}
+
+	return RouteConfig{children: []RouteConfig{
+		Describe(
+			"The current gc percent, memory limit, GOMAXPROCS and profiling rates.",
+			WithGenericValue("/runtime/tuning", tuner.settings)),
+
+		Describe(
+			"Changes gc percent, memory limit, GOMAXPROCS or profiling rates, optionally for a 'ttl'.",
+			WithHandlerFunc("PUT", "/runtime/tuning", tuner.handleUpdate)),
+
+		Describe(
+			"Changes gc percent, memory limit, GOMAXPROCS or profiling rates, optionally for a 'ttl'.",
+			WithHandlerFunc("POST", "/runtime/tuning", tuner.handleUpdate)),
+	}}
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Header that can be used to send an api token instead
+// of using the Authorization header.
+const TokenHeader = "X-Admin-Token"
+
+// Maps the sha256 hashes of the tokens to their names.
+type tokens map[[sha256.Size]byte]string
+
+type tokenAuth struct {
+	tokens func() tokens
+}
+
+func (auth tokenAuth) Authenticate(req *http.Request) (Identity, bool) {
+	token := requestToken(req)
+	if token == "" {
+		return Identity{}, false
+	}
+
+	// we compare hashes, so a lookup leaks nothing about the real tokens.
+	name, ok := auth.tokens()[sha256.Sum256([]byte(token))]
+	if !ok {
+		return Identity{}, false
+	}
+
+	return Identity{Name: name, Scheme: "token"}, true
+}
+
+func (auth tokenAuth) Challenge() string {
+	return "Bearer"
+}
+
+// Extracts the token from an "Authorization: Bearer" header or from the TokenHeader.
+func requestToken(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+
+	return strings.TrimSpace(req.Header.Get(TokenHeader))
+}
+
+// Creates an authenticator that accepts api tokens, either as bearer token
+// in the Authorization header or in the X-Admin-Token header. The map contains
+// the name of each token, used as the name of the callers identity, mapped to
+// the token itself.
+func StaticTokens(namedTokens map[string]string) (Authenticator, error) {
+	parsed := tokens{}
+	for name, token := range namedTokens {
+		if err := parsed.add(name, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return tokenAuth{tokens: func() tokens { return parsed }}, nil
+}
+
+// Creates an authenticator that accepts the api tokens listed in a file, one
+// 'name:token' pair per line. Empty lines and lines starting with # are ignored.
+// The file is reloaded if it changes, see HtpasswdFile for details.
+func TokenFile(path string, reloadInterval time.Duration) (Authenticator, error) {
+	file, err := newReloadingFile(path, reloadInterval, parseTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenAuth{tokens: func() tokens { return file.get().(tokens) }}, nil
+}
+
+func parseTokens(content []byte) (interface{}, error) {
+	parsed := tokens{}
+	err := parseColonSeparated(content, func(name, token string) error {
+		return parsed.add(name, strings.TrimSpace(token))
+	})
+
+	return parsed, err
+}
+
+func (t tokens) add(name, token string) error {
+	if token == "" {
+		return fmt.Errorf("token %q is empty", name)
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	if other, exists := t[hash]; exists {
+		return fmt.Errorf("tokens %q and %q are identical", other, name)
+	}
+
+	t[hash] = name
+	return nil
+}
+
+type anyAuthenticator []Authenticator
+
+// Combines multiple authenticators. A request is authenticated by the first
+// authenticator that accepts its credentials, e.g. to let people use basic
+// auth while scripts use api tokens.
+func AnyOf(authenticators ...Authenticator) Authenticator {
+	return anyAuthenticator(authenticators)
+}
+
+func (authenticators anyAuthenticator) Authenticate(req *http.Request) (Identity, bool) {
+	for _, auth := range authenticators {
+		if identity, ok := auth.Authenticate(req); ok {
+			return identity, true
+		}
+	}
+
+	return Identity{}, false
+}
+
+func (authenticators anyAuthenticator) Challenge() string {
+	var challenges []string
+	for _, auth := range authenticators {
+		if challenger, ok := auth.(challenger); ok {
+			challenges = append(challenges, challenger.Challenge())
+		}
+	}
+
+	return strings.Join(challenges, ", ")
+}
@@ -7,6 +7,14 @@ import (
 	"reflect"
 )
 
+type contextKey int
+
+const (
+	pathParamsKey contextKey = iota
+	identityKey
+	auditRecordKey
+)
+
 // This method returns a handler, that produces json obtained from the given
 // input value. The input value might either be just some ordinary value that
 // can be marshaled using json.Marshal or a parameterless function that returns